@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Alfarabi09/Exchange_Rates/exchangerates"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// Размеры изображения графика
+const (
+	chartWidth  = 8 * vg.Inch
+	chartHeight = 4 * vg.Inch
+)
+
+// writeChart строит линейный график курса валюты по датам и сохраняет его в PNG-файл
+func writeChart(path string, stats exchangerates.CurrencyStats) error {
+	if len(stats.Series) == 0 {
+		return fmt.Errorf("Нет данных для построения графика курса %s", stats.CharCode)
+	}
+
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("%s (%s), номинал %d", stats.CurrencyName, stats.CharCode, stats.Nominal)
+	p.X.Label.Text = "Дата"
+	p.Y.Label.Text = "Курс"
+	p.X.Tick.Marker = plot.TimeTicks{Format: "02.01.2006"}
+
+	points := make(plotter.XYs, len(stats.Series))
+	for i, point := range stats.Series {
+		points[i].X = float64(point.Date.Unix())
+		points[i].Y = point.Value
+	}
+
+	line, err := plotter.NewLine(points)
+	if err != nil {
+		return fmt.Errorf("Ошибка при построении графика: %w", err)
+	}
+	p.Add(plotter.NewGrid(), line)
+
+	writer, err := p.WriterTo(chartWidth, chartHeight, "png")
+	if err != nil {
+		return fmt.Errorf("Ошибка при построении графика: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Ошибка при создании файла графика: %w", err)
+	}
+	if _, err := writer.WriteTo(file); err != nil {
+		file.Close()
+		return fmt.Errorf("Ошибка при записи файла графика: %w", err)
+	}
+	return file.Close()
+}
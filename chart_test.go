@@ -0,0 +1,39 @@
+package main
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteChart(t *testing.T) {
+	stats := testStats(t, testValute{"USD", "840", "Доллар США", 1, []string{"90", "91,5", "89,2", "92"}})
+	path := filepath.Join(t.TempDir(), "usd.png")
+	if err := writeChart(path, stats["USD"]); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatalf("некорректный PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() == 0 || b.Dy() == 0 {
+		t.Errorf("размер изображения = %v", b)
+	}
+}
+
+func TestWriteChartNoData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usd.png")
+	if err := writeChart(path, testStats(t, testValute{"USD", "840", "Доллар США", 1, []string{"90"}})["EUR"]); err == nil {
+		t.Error("ожидалась ошибка для валюты без данных")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("файл графика создан без данных: %v", err)
+	}
+}
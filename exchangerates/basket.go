@@ -0,0 +1,40 @@
+package exchangerates
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Basket рассчитывает по дням индекс корзины валют: сумму курсов за единицу валюты,
+// умноженных на веса weights. Учитываются только даты, за которые известны курсы
+// всех валют корзины. Точки возвращаются в хронологическом порядке.
+func Basket(stats map[string]CurrencyStats, weights map[string]float64) ([]RatePoint, error) {
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("Корзина валют пуста")
+	}
+
+	values := make(map[time.Time]float64)
+	counts := make(map[time.Time]int)
+	for code, weight := range weights {
+		s, ok := stats[code]
+		if !ok {
+			return nil, fmt.Errorf("Валюта корзины %s не найдена в данных", code)
+		}
+		for _, p := range s.Series {
+			values[p.Date] += weight * s.perUnit(p.Value)
+			counts[p.Date]++
+		}
+	}
+
+	var points []RatePoint
+	for date, value := range values {
+		if counts[date] == len(weights) {
+			points = append(points, RatePoint{Date: date, Value: value})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Date.Before(points[j].Date)
+	})
+	return points, nil
+}
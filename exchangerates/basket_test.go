@@ -0,0 +1,43 @@
+package exchangerates
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBasket(t *testing.T) {
+	store := NewStatsStore()
+	first := newDay(testDate(time.March, 4), map[string]string{"USD": "90", "EUR": "100"})
+	first.Valutes = append(first.Valutes, Valute{ID: "R01820", NumCode: "392", CharCode: "JPY", Nominal: 100, Name: "Японских иен", Value: "60"})
+	store.Update(first)
+	second := newDay(testDate(time.March, 5), map[string]string{"USD": "91", "EUR": "99"})
+	second.Valutes = append(second.Valutes, Valute{ID: "R01820", NumCode: "392", CharCode: "JPY", Nominal: 100, Name: "Японских иен", Value: "61"})
+	store.Update(second)
+	// День без курса EUR не входит в ряд корзины
+	store.Update(newDay(testDate(time.March, 6), map[string]string{"USD": "92"}))
+
+	points, err := Basket(store.Snapshot(), map[string]float64{"USD": 0.6, "EUR": 0.3, "JPY": 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RatePoint{
+		{Date: testDate(time.March, 4), Value: 0.6*90 + 0.3*100 + 0.1*0.6}, // Курс иены указан за 100 единиц
+		{Date: testDate(time.March, 5), Value: 0.6*91 + 0.3*99 + 0.1*0.61},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("points = %v, want %v", points, want)
+	}
+	for i := range want {
+		if !points[i].Date.Equal(want[i].Date) || math.Abs(points[i].Value-want[i].Value) > 1e-9 {
+			t.Errorf("points[%d] = %v, want %v", i, points[i], want[i])
+		}
+	}
+
+	if _, err := Basket(store.Snapshot(), map[string]float64{"USD": 0.5, "GBP": 0.5}); err == nil {
+		t.Error("Basket(GBP): ожидалась ошибка для валюты без курсов")
+	}
+	if _, err := Basket(store.Snapshot(), nil); err == nil {
+		t.Error("Basket(nil): ожидалась ошибка для пустой корзины")
+	}
+}
@@ -0,0 +1,162 @@
+package exchangerates
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCacheDir задаёт каталог файлового кэша ответов API по умолчанию
+const DefaultCacheDir = "./cache"
+
+// cachePath возвращает путь к файлу кэша с ответом API за указанную дату
+func cachePath(dir string, d time.Time) string {
+	return filepath.Join(dir, d.Format(isoDateLayout)+".xml")
+}
+
+// DefaultCacheTTL задаёт по умолчанию срок хранения в кэше ответов за текущую и будущие даты
+const DefaultCacheTTL = time.Hour
+
+// cacheState описывает состояние сохранённого ответа API за дату (см. readCache)
+type cacheState int
+
+const (
+	cacheMissing cacheState = iota // Ответа нет или файл пуст
+	cacheFresh                     // Ответ можно использовать без обращения к API
+	cacheStale                     // Ответ за текущую дату старше ttl и должен быть проверен или загружен заново
+)
+
+// readCache возвращает сохранённый ответ API за дату и его состояние.
+// Курсы за прошедшие даты не меняются, поэтому хранятся бессрочно, а ответ за текущую
+// или будущую дату (относительно now) может обновляться и считается устаревшим через ttl.
+// При ttl <= 0 ответы не устаревают. Устаревший ответ возвращается вместе с состоянием
+// cacheStale, чтобы его можно было использовать после условного запроса.
+func readCache(dir string, d time.Time, ttl time.Duration, now time.Time) (string, cacheState) {
+	path := cachePath(dir, d)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", cacheMissing
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return "", cacheMissing
+	}
+
+	if ttl > 0 && isCurrentDate(d, now) {
+		if age := now.Sub(info.ModTime()); age > ttl {
+			slog.Debug("Ответ в кэше устарел", "date", d.Format(isoDateLayout), "age", age)
+			return string(data), cacheStale
+		}
+	}
+	return string(data), cacheFresh
+}
+
+// touchCache обновляет время изменения ответа в кэше после того, как API подтвердил его актуальность,
+// чтобы срок хранения отсчитывался заново
+func touchCache(dir string, d time.Time, now time.Time) error {
+	return os.Chtimes(cachePath(dir, d), now, now)
+}
+
+// isCurrentDate определяет, приходится ли дата d на сегодняшний день now или позже
+func isCurrentDate(d, now time.Time) bool {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return !d.Before(today)
+}
+
+// writeCache сохраняет ответ API за дату в каталог кэша, создавая каталог при необходимости
+func writeCache(dir string, d time.Time, data string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("Ошибка при создании каталога кэша: %w", err)
+	}
+	if err := writeFileAtomic(cachePath(dir, d), []byte(data)); err != nil {
+		return fmt.Errorf("Ошибка при записи кэша: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic записывает данные во временный файл в том же каталоге и переименовывает его в path,
+// чтобы прерванная запись не оставила в кэше обрезанный файл
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // После успешного переименования файла уже нет
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// cacheMeta содержит заголовки ответа API, необходимые для условных запросов
+type cacheMeta struct {
+	ETag         string `json:"etag,omitempty"`          // Значение заголовка ETag
+	LastModified string `json:"last_modified,omitempty"` // Значение заголовка Last-Modified
+}
+
+// newCacheMeta извлекает заголовки для условных запросов из заголовков ответа
+func newCacheMeta(header http.Header) cacheMeta {
+	return cacheMeta{ETag: header.Get("ETag"), LastModified: header.Get("Last-Modified")}
+}
+
+// conditional возвращает заголовки условного запроса или nil, если сервер не прислал ни ETag, ни Last-Modified
+func (m cacheMeta) conditional() http.Header {
+	if m.ETag == "" && m.LastModified == "" {
+		return nil
+	}
+
+	header := make(http.Header)
+	if m.ETag != "" {
+		header.Set("If-None-Match", m.ETag)
+	}
+	if m.LastModified != "" {
+		header.Set("If-Modified-Since", m.LastModified)
+	}
+	return header
+}
+
+// cacheMetaPath возвращает путь к файлу с заголовками ответа API за указанную дату
+func cacheMetaPath(dir string, d time.Time) string {
+	return filepath.Join(dir, d.Format(isoDateLayout)+".meta.json")
+}
+
+// readCacheMeta возвращает сохранённые заголовки ответа API за дату.
+// При отсутствии или повреждении файла возвращаются пустые заголовки.
+func readCacheMeta(dir string, d time.Time) cacheMeta {
+	var meta cacheMeta
+	data, err := os.ReadFile(cacheMetaPath(dir, d))
+	if err == nil {
+		json.Unmarshal(data, &meta)
+	}
+	return meta
+}
+
+// writeCacheMeta сохраняет заголовки ответа API за дату рядом с ответом в кэше.
+// Если сервер не прислал заголовков для условных запросов, файл не создаётся.
+func writeCacheMeta(dir string, d time.Time, meta cacheMeta) error {
+	if meta.conditional() == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("Ошибка при формировании заголовков кэша: %w", err)
+	}
+	if err := writeFileAtomic(cacheMetaPath(dir, d), data); err != nil {
+		return fmt.Errorf("Ошибка при записи заголовков кэша: %w", err)
+	}
+	return nil
+}
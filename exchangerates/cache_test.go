@@ -0,0 +1,117 @@
+package exchangerates
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadCacheTTL(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	today, yesterday := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	for _, d := range []time.Time{today, yesterday} {
+		if err := writeCache(dir, d, "<ValCurs/>"); err != nil {
+			t.Fatal(err)
+		}
+		// Оба ответа сохранены два часа назад
+		if err := os.Chtimes(cachePath(dir, d), now.Add(-2*time.Hour), now.Add(-2*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		date  time.Time
+		ttl   time.Duration
+		state cacheState
+	}{
+		{"текущая дата после ttl", today, time.Hour, cacheStale},
+		{"текущая дата до ttl", today, 3 * time.Hour, cacheFresh},
+		{"прошедшая дата", yesterday, time.Hour, cacheFresh},
+		{"без ограничения срока", today, 0, cacheFresh},
+		{"нет ответа", yesterday.AddDate(0, 0, -1), time.Hour, cacheMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, state := readCache(dir, tt.date, tt.ttl, now)
+			if state != tt.state || (state != cacheMissing) != (data != "") {
+				t.Errorf("readCache(%s, %v) = %q, %v, want %v", tt.date.Format(isoDateLayout), tt.ttl, data, state, tt.state)
+			}
+		})
+	}
+}
+
+func TestFetcherRefetchesExpiredCache(t *testing.T) {
+	var calls atomic.Int32
+	server := failingServer(t, 0, 0, readTestdata(t, "XML_daily_eng.xml"), &calls)
+	dir := t.TempDir()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	past := today.AddDate(0, 0, -30)
+
+	fetch := func(d time.Time) string {
+		t.Helper()
+		f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", CacheDir: dir, CacheTTL: time.Hour}
+		valCurs, err := f.FetchRates(context.Background(), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return valCurs.Origin
+	}
+	for _, d := range []time.Time{today, past} {
+		if origin := fetch(d); origin != OriginNetwork {
+			t.Fatalf("%s: Origin = %q, want %q", d.Format(isoDateLayout), origin, OriginNetwork)
+		}
+		// Ответы устаревают на два часа больше ttl
+		old := now.Add(-2 * time.Hour)
+		if err := os.Chtimes(cachePath(dir, d), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if origin := fetch(today); origin != OriginNetwork {
+		t.Errorf("today: Origin = %q, want %q: устаревший ответ за текущую дату загружается заново", origin, OriginNetwork)
+	}
+	if origin := fetch(past); origin != OriginCache {
+		t.Errorf("past: Origin = %q, want %q: ответ за прошедшую дату хранится бессрочно", origin, OriginCache)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+}
+
+func TestFetcherRepairsCorruptCache(t *testing.T) {
+	body := readTestdata(t, "XML_daily_eng.xml")
+	var calls atomic.Int32
+	server := failingServer(t, 0, 0, body, &calls)
+	dir := t.TempDir()
+	date := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	// Запись в кэш прервана на середине ответа
+	if err := os.WriteFile(cachePath(dir, date), []byte(body[:len(body)/2]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for run, origin := range []string{OriginNetwork, OriginCache} {
+		f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", CacheDir: dir}
+		valCurs, err := f.FetchRates(context.Background(), date)
+		if err != nil {
+			t.Fatalf("run %d: %v", run+1, err)
+		}
+		if valCurs.Origin != origin || len(valCurs.Valutes) == 0 {
+			t.Errorf("run %d: Origin = %q, want %q", run+1, valCurs.Origin, origin)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1: повреждённый ответ загружается заново один раз", calls.Load())
+	}
+	if data, err := os.ReadFile(cachePath(dir, date)); err != nil || string(data) != body {
+		t.Errorf("кэш не восстановлен: %d байт, %v", len(data), err)
+	}
+	// Временные файлы атомарной записи не остаются в каталоге кэша
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("файлов в кэше = %d, %v, want 1", len(entries), err)
+	}
+}
@@ -0,0 +1,95 @@
+package exchangerates
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// currencyNumCodes сопоставляет символьные коды валют ISO 4217 с цифровыми.
+// Используется для источников, которые публикуют только символьные коды,
+// и для проверки кодов валют ЦБ РФ.
+var currencyNumCodes = map[string]string{
+	"AED": "784",
+	"AMD": "051",
+	"AUD": "036",
+	"AZN": "944",
+	"BDT": "050",
+	"BGN": "975",
+	"BHD": "048",
+	"BOB": "068",
+	"BRL": "986",
+	"BYN": "933",
+	"CAD": "124",
+	"CHF": "756",
+	"CNY": "156",
+	"CUP": "192",
+	"CZK": "203",
+	"DKK": "208",
+	"DZD": "012",
+	"EGP": "818",
+	"ETB": "230",
+	"EUR": "978",
+	"GBP": "826",
+	"GEL": "981",
+	"HKD": "344",
+	"HUF": "348",
+	"IDR": "360",
+	"ILS": "376",
+	"INR": "356",
+	"IRR": "364",
+	"ISK": "352",
+	"JPY": "392",
+	"KGS": "417",
+	"KRW": "410",
+	"KZT": "398",
+	"MDL": "498",
+	"MMK": "104",
+	"MNT": "496",
+	"MXN": "484",
+	"MYR": "458",
+	"NGN": "566",
+	"NOK": "578",
+	"NZD": "554",
+	"OMR": "512",
+	"PHP": "608",
+	"PLN": "985",
+	"QAR": "634",
+	"RON": "946",
+	"RSD": "941",
+	"RUB": "643",
+	"SAR": "682",
+	"SEK": "752",
+	"SGD": "702",
+	"THB": "764",
+	"TJS": "972",
+	"TMT": "934",
+	"TRY": "949",
+	"UAH": "980",
+	"USD": "840",
+	"UZS": "860",
+	"VND": "704",
+	"XDR": "960",
+	"ZAR": "710",
+}
+
+// normalizeCodes приводит коды валюты к виду ISO 4217: символьный код к верхнему регистру,
+// цифровой — к трём цифрам с ведущими нулями. Несоответствие пары кодов справочнику
+// currencyNumCodes журналируется как предупреждение.
+func normalizeCodes(date string, v Valute) Valute {
+	v.CharCode = strings.ToUpper(strings.TrimSpace(v.CharCode))
+	v.NumCode = strings.TrimSpace(v.NumCode)
+	if len(v.NumCode) < 3 {
+		v.NumCode = fmt.Sprintf("%03s", v.NumCode)
+	}
+
+	known, ok := currencyNumCodes[v.CharCode]
+	switch {
+	case !ok:
+		slog.Debug("Валюта отсутствует в справочнике ISO 4217", "date", date, "char_code", v.CharCode, "num_code", v.NumCode)
+	case known != v.NumCode:
+		slog.Warn("Цифровой код валюты не соответствует ISO 4217", "date", date,
+			"char_code", v.CharCode, "num_code", v.NumCode, "expected", known)
+	}
+	return v
+}
@@ -0,0 +1,312 @@
+package exchangerates
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite" // Драйвер SQLite
+)
+
+// createRatesTable создаёт таблицу курсов валют, если она ещё не существует.
+// Курсы разных источников хранятся раздельно по валюте, в которой они выражены (base).
+const createRatesTable = `CREATE TABLE IF NOT EXISTS rates (
+	date      TEXT    NOT NULL,
+	base      TEXT    NOT NULL,
+	char_code TEXT    NOT NULL,
+	num_code  TEXT    NOT NULL,
+	nominal   INTEGER NOT NULL,
+	name      TEXT    NOT NULL,
+	value     REAL    NOT NULL,
+	PRIMARY KEY (date, base, char_code)
+)`
+
+// createFetchedTable создаёт таблицу загруженных дат, если она ещё не существует.
+// ЦБ РФ на дату без установленных курсов возвращает курсы предыдущего рабочего дня,
+// поэтому запрошенная дата хранится отдельно от даты курсов в таблице rates.
+const createFetchedTable = `CREATE TABLE IF NOT EXISTS fetched (
+	base           TEXT NOT NULL,
+	requested_date TEXT NOT NULL,
+	returned_date  TEXT NOT NULL,
+	PRIMARY KEY (base, requested_date)
+)`
+
+// seedFetched отмечает загруженными даты курсов, сохранённых до появления таблицы fetched
+const seedFetched = `INSERT OR IGNORE INTO fetched (base, requested_date, returned_date)
+SELECT DISTINCT base, date, date FROM rates`
+
+// upsertFetched сохраняет дату курсов, полученных на запрошенную дату
+const upsertFetched = `INSERT INTO fetched (base, requested_date, returned_date)
+VALUES (?, ?, ?)
+ON CONFLICT (base, requested_date) DO UPDATE SET
+	returned_date = excluded.returned_date`
+
+// selectRatesColumns выбирает имена столбцов таблицы курсов, если она существует
+const selectRatesColumns = `SELECT name FROM pragma_table_info('rates')`
+
+// migrateRatesBase переносит курсы из таблицы без столбца base в новую таблицу.
+// Такие курсы сохранялись только из ЦБ РФ и выражены в рублях.
+var migrateRatesBase = []string{
+	`ALTER TABLE rates RENAME TO rates_old`,
+	createRatesTable,
+	`INSERT INTO rates (date, base, char_code, num_code, nominal, name, value)
+SELECT date, 'RUB', char_code, num_code, nominal, name, value FROM rates_old`,
+	`DROP TABLE rates_old`,
+}
+
+// upsertRate добавляет курс валюты за день или обновляет уже сохранённый
+const upsertRate = `INSERT INTO rates (date, base, char_code, num_code, nominal, name, value)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (date, base, char_code) DO UPDATE SET
+	num_code = excluded.num_code,
+	nominal  = excluded.nominal,
+	name     = excluded.name,
+	value    = excluded.value`
+
+// selectRates выбирает курсы валют в базовой валюте за период в хронологическом порядке
+const selectRates = `SELECT date, char_code, num_code, nominal, name, value
+FROM rates
+WHERE base = ? AND date BETWEEN ? AND ?
+ORDER BY date, char_code`
+
+// selectFetchedDates выбирает запрошенные даты периода, курсы на которые в базовой валюте уже загружены,
+// вместе с датами полученных курсов
+const selectFetchedDates = `SELECT requested_date, returned_date
+FROM fetched
+WHERE base = ? AND requested_date BETWEEN ? AND ?`
+
+// RateDB хранит ежедневные курсы валют в базе данных SQLite
+type RateDB struct {
+	db *sql.DB
+}
+
+// OpenRateDB открывает базу данных SQLite по указанному пути и создаёт таблицу курсов.
+// Путь ":memory:" открывает базу данных в памяти.
+func OpenRateDB(path string) (*RateDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при открытии базы данных: %w", err)
+	}
+	db.SetMaxOpenConns(1) // SQLite не поддерживает параллельную запись, а база в памяти существует в рамках одного соединения
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(createRatesTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Ошибка при создании таблицы курсов: %w", err)
+	}
+	if _, err := db.Exec(createFetchedTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Ошибка при создании таблицы загруженных дат: %w", err)
+	}
+	if _, err := db.Exec(seedFetched); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Ошибка при заполнении таблицы загруженных дат: %w", err)
+	}
+	return &RateDB{db: db}, nil
+}
+
+// migrate добавляет столбец base в таблицу курсов, созданную без него
+func migrate(db *sql.DB) error {
+	rows, err := db.Query(selectRatesColumns)
+	if err != nil {
+		return fmt.Errorf("Ошибка при чтении структуры таблицы курсов: %w", err)
+	}
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("Ошибка при чтении структуры таблицы курсов: %w", err)
+		}
+		columns = append(columns, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Ошибка при чтении структуры таблицы курсов: %w", err)
+	}
+	if len(columns) == 0 || slices.Contains(columns, "base") {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("Ошибка при начале транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range migrateRatesBase {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("Ошибка при обновлении таблицы курсов: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Close закрывает базу данных
+func (r *RateDB) Close() error {
+	return r.db.Close()
+}
+
+// Save сохраняет курсы валют за день одной транзакцией вместе с валютой, в которой они выражены,
+// и отмечает загруженной запрошенную дату (ValCurs.Request, а если она не задана — дату курсов)
+func (r *RateDB) Save(valCurs ValCurs) error {
+	if valCurs.Time.IsZero() {
+		return fmt.Errorf("Не задана дата курсов %q", valCurs.Date)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Ошибка при начале транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, valute := range valCurs.Valutes {
+		value, err := valute.FloatValue()
+		if errors.Is(err, ErrEmptyValue) {
+			continue // Валюты без курса за день не сохраняются
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(upsertRate, valCurs.Time.Format(isoDateLayout), valCurs.BaseCurrency(), valute.CharCode,
+			valute.NumCode, valute.Nominal, valute.Name, value)
+		if err != nil {
+			return fmt.Errorf("Ошибка при сохранении курса валюты %s: %w", valute.CharCode, err)
+		}
+	}
+
+	requested := valCurs.Request
+	if requested.IsZero() {
+		requested = valCurs.Time
+	}
+	_, err = tx.Exec(upsertFetched, valCurs.BaseCurrency(), requested.Format(isoDateLayout), valCurs.Time.Format(isoDateLayout))
+	if err != nil {
+		return fmt.Errorf("Ошибка при сохранении загруженной даты %s: %w", requested.Format(isoDateLayout), err)
+	}
+
+	return tx.Commit()
+}
+
+// MissingDates возвращает даты из dates, курсы на которые в валюте base ещё не загружались.
+// Дата считается загруженной, если ответ на неё сохранён, даже если он содержит курсы за другую дату.
+func (r *RateDB) MissingDates(base string, dates []time.Time) ([]time.Time, error) {
+	fetched, err := r.fetchedDates(base, dates)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []time.Time
+	for _, d := range dates {
+		if _, ok := fetched[d.Format(isoDateLayout)]; !ok {
+			missing = append(missing, d)
+		}
+	}
+	return missing, nil
+}
+
+// fetchedDates возвращает даты курсов в валюте base, полученных на запрошенные даты
+// из периода dates, по запрошенной дате в формате ГГГГ-ММ-ДД
+func (r *RateDB) fetchedDates(base string, dates []time.Time) (map[string]string, error) {
+	if len(dates) == 0 {
+		return nil, nil
+	}
+
+	start, end := dates[0], dates[0]
+	for _, d := range dates {
+		if d.Before(start) {
+			start = d
+		}
+		if d.After(end) {
+			end = d
+		}
+	}
+
+	rows, err := r.db.Query(selectFetchedDates, base, start.Format(isoDateLayout), end.Format(isoDateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при чтении дат из базы данных: %w", err)
+	}
+	defer rows.Close()
+
+	fetched := make(map[string]string)
+	for rows.Next() {
+		var requested, returned string
+		if err := rows.Scan(&requested, &returned); err != nil {
+			return nil, fmt.Errorf("Ошибка при чтении дат из базы данных: %w", err)
+		}
+		fetched[requested] = returned
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Ошибка при чтении дат из базы данных: %w", err)
+	}
+	return fetched, nil
+}
+
+// Load читает курсы валют в валюте base, полученные на запрошенные даты dates,
+// группируя их по дням в хронологическом порядке. Курсы, полученные сразу
+// на несколько запрошенных дат (например, на выходные), возвращаются один раз.
+func (r *RateDB) Load(base string, dates []time.Time) ([]ValCurs, error) {
+	fetched, err := r.fetchedDates(base, dates)
+	if err != nil {
+		return nil, err
+	}
+
+	returned := make(map[string]bool)
+	var start, end string
+	for _, d := range dates {
+		date, ok := fetched[d.Format(isoDateLayout)]
+		if !ok {
+			continue
+		}
+		returned[date] = true
+		if start == "" || date < start {
+			start = date
+		}
+		if date > end {
+			end = date
+		}
+	}
+	if len(returned) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.db.Query(selectRates, base, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при чтении курсов из базы данных: %w", err)
+	}
+	defer rows.Close()
+
+	var days []ValCurs
+	for rows.Next() {
+		var date string
+		var valute Valute
+		var value float64
+		if err := rows.Scan(&date, &valute.CharCode, &valute.NumCode, &valute.Nominal, &valute.Name, &value); err != nil {
+			return nil, fmt.Errorf("Ошибка при чтении курсов из базы данных: %w", err)
+		}
+		valute.Value = strconv.FormatFloat(value, 'f', -1, 64)
+
+		if !returned[date] {
+			continue
+		}
+		d, err := time.Parse(isoDateLayout, date)
+		if err != nil {
+			return nil, fmt.Errorf("Некорректная дата в базе данных %q: %w", date, err)
+		}
+		if len(days) == 0 || !days[len(days)-1].Time.Equal(d) {
+			days = append(days, ValCurs{Date: d.Format(valCursDateLayout), Time: d, Base: base})
+		}
+		days[len(days)-1].Valutes = append(days[len(days)-1].Valutes, valute)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Ошибка при чтении курсов из базы данных: %w", err)
+	}
+	return days, nil
+}
@@ -0,0 +1,190 @@
+package exchangerates
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// openTestDB открывает базу данных в памяти и закрывает её по завершении теста
+func openTestDB(t *testing.T) *RateDB {
+	t.Helper()
+	db, err := OpenRateDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// testDay возвращает курсы за день с одной валютой
+func testDay(date time.Time, base, code, value string) ValCurs {
+	return ValCurs{
+		Date:    date.Format(valCursDateLayout),
+		Time:    date,
+		Base:    base,
+		Valutes: []Valute{{ID: code, NumCode: currencyNumCodes[code], CharCode: code, Nominal: 1, Name: code, Value: value}},
+	}
+}
+
+func TestRateDBSeparatesBases(t *testing.T) {
+	db := openTestDB(t)
+	date := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if err := db.Save(testDay(date, "", "USD", "90,1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(testDay(date, BaseEUR, "USD", "0.92")); err != nil {
+		t.Fatal(err)
+	}
+
+	for base, want := range map[string]string{BaseRUB: "90.1", BaseEUR: "0.92"} {
+		days, err := db.Load(base, []time.Time{date})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(days) != 1 || len(days[0].Valutes) != 1 || days[0].Valutes[0].Value != want {
+			t.Fatalf("Load(%s) = %+v, want USD %s", base, days, want)
+		}
+		if days[0].BaseCurrency() != base {
+			t.Errorf("BaseCurrency() = %q, want %q", days[0].BaseCurrency(), base)
+		}
+	}
+
+	missing, err := db.MissingDates("USD", []time.Time{date})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 1 {
+		t.Errorf("MissingDates(USD) = %v, want %v", missing, date)
+	}
+}
+
+func TestOpenRateDBMigratesBase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.db")
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = old.Exec(`CREATE TABLE rates (
+	date TEXT NOT NULL, char_code TEXT NOT NULL, num_code TEXT NOT NULL,
+	nominal INTEGER NOT NULL, name TEXT NOT NULL, value REAL NOT NULL,
+	PRIMARY KEY (date, char_code));
+INSERT INTO rates VALUES ('2024-02-01', 'USD', '840', 1, 'Dollar', 90.1)`)
+	old.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenRateDB(path)
+	if err != nil {
+		t.Fatalf("OpenRateDB: %v", err)
+	}
+	defer db.Close()
+
+	date := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	days, err := db.Load(BaseRUB, []time.Time{date})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].Valutes[0].Value != "90.1" {
+		t.Fatalf("Load = %+v, want USD 90.1", days)
+	}
+}
+
+func TestMissingDatesUsesRequestedDate(t *testing.T) {
+	db := openTestDB(t)
+	requested := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	returned := time.Date(2023, 12, 30, 0, 0, 0, 0, time.UTC)
+
+	// На праздничный день ЦБ РФ возвращает курсы предыдущего рабочего дня
+	day := testDay(returned, "", "USD", "89,6883")
+	day.Request = requested
+	if err := db.Save(day); err != nil {
+		t.Fatal(err)
+	}
+
+	next := requested.AddDate(0, 0, 1)
+	missing, err := db.MissingDates(BaseRUB, []time.Time{returned, requested, next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 2 || !missing[0].Equal(returned) || !missing[1].Equal(next) {
+		t.Errorf("MissingDates = %v, want [%v %v]", missing, returned, next)
+	}
+}
+
+func TestLoadRequestedDates(t *testing.T) {
+	db := openTestDB(t)
+	friday := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	for i, value := range []string{"90,1", "90,2", "90,3"} {
+		day := testDay(friday.AddDate(0, 0, 3*i-3), "", "USD", value)
+		if err := db.Save(day); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// На субботу ЦБ РФ возвращает курсы за пятницу
+	saturday := testDay(friday, "", "USD", "90,2")
+	saturday.Request = friday.AddDate(0, 0, 1)
+	if err := db.Save(saturday); err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := db.Load(BaseRUB, []time.Time{friday, saturday.Request, friday.AddDate(0, 0, 3), friday.AddDate(0, 0, 4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, day := range days {
+		got = append(got, day.Date)
+	}
+	// Курсы за 30.01 не запрашивались, а курсы за пятницу возвращаются один раз
+	if want := []string{"02.02.2024", "05.02.2024"}; !slices.Equal(got, want) {
+		t.Errorf("Load = %v, want %v", got, want)
+	}
+}
+
+func TestRateDBSaveLoad(t *testing.T) {
+	db := openTestDB(t)
+	first, second := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	day := ValCurs{Date: "01.02.2024", Time: first, Valutes: []Valute{
+		{ID: "R01235", NumCode: "840", CharCode: "USD", Nominal: 1, Name: "Доллар США", Value: "89,5"},
+		{ID: "R01820", NumCode: "392", CharCode: "JPY", Nominal: 100, Name: "Японских иен", Value: "61,5419"},
+	}}
+	for _, valCurs := range []ValCurs{day, testDay(second, "", "USD", "90,1")} {
+		if err := db.Save(valCurs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Повторное сохранение дня обновляет курс, а не добавляет строку
+	day.Valutes[0].Value = "89,6"
+	if err := db.Save(day); err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := db.Load(BaseRUB, []time.Time{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 || days[0].Date != "01.02.2024" || days[1].Date != "02.02.2024" {
+		t.Fatalf("Load = %+v, want два дня", days)
+	}
+	if len(days[0].Valutes) != 2 || len(days[1].Valutes) != 1 {
+		t.Fatalf("valutes = %d и %d, want 2 и 1", len(days[0].Valutes), len(days[1].Valutes))
+	}
+	for _, v := range days[0].Valutes {
+		switch v.CharCode {
+		case "USD":
+			if v.Value != "89.6" || v.NumCode != "840" || v.Nominal != 1 || v.Name != "Доллар США" {
+				t.Errorf("USD = %+v", v)
+			}
+		case "JPY":
+			if v.Value != "61.5419" || v.Nominal != 100 {
+				t.Errorf("JPY = %+v", v)
+			}
+		default:
+			t.Errorf("неожиданная валюта %+v", v)
+		}
+	}
+}
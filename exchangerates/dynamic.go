@@ -0,0 +1,164 @@
+package exchangerates
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CBRDynamicURL задаёт шаблон URL динамики курса одной валюты ЦБ РФ за период:
+// начальная и конечная даты (дд/мм/гггг) и внутренний код валюты ЦБ РФ
+const CBRDynamicURL = "http://www.cbr.ru/scripts/XML_dynamic.asp?date_req1=%s&date_req2=%s&VAL_NM_RQ=%s"
+
+// cbrCurrencyIDs сопоставляет символьные коды валют с внутренними кодами ЦБ РФ (атрибут ID)
+var cbrCurrencyIDs = map[string]string{
+	"AMD": "R01060",
+	"AUD": "R01010",
+	"AZN": "R01020A",
+	"BYN": "R01090B",
+	"CAD": "R01350",
+	"CHF": "R01775",
+	"CNY": "R01375",
+	"CZK": "R01760",
+	"DKK": "R01215",
+	"EUR": "R01239",
+	"GBP": "R01035",
+	"HKD": "R01200",
+	"HUF": "R01135",
+	"INR": "R01270",
+	"JPY": "R01820",
+	"KGS": "R01370",
+	"KRW": "R01815",
+	"KZT": "R01335",
+	"MDL": "R01500",
+	"NOK": "R01535",
+	"PLN": "R01565",
+	"SEK": "R01770",
+	"SGD": "R01625",
+	"TJS": "R01670",
+	"TRY": "R01700J",
+	"UAH": "R01720",
+	"USD": "R01235",
+	"UZS": "R01717",
+	"XDR": "R01589",
+	"ZAR": "R01810",
+}
+
+// CBRCurrencyID возвращает внутренний код ЦБ РФ для символьного кода валюты
+func CBRCurrencyID(code string) (string, error) {
+	id, ok := cbrCurrencyIDs[strings.ToUpper(code)]
+	if !ok {
+		return "", fmt.Errorf("Неизвестен внутренний код ЦБ РФ для валюты %s", code)
+	}
+	return id, nil
+}
+
+// dynamicEnvelope представляет корневой элемент ответа XML_dynamic ЦБ РФ
+type dynamicEnvelope struct {
+	ID      string          `xml:"ID,attr"` // Внутренний код валюты ЦБ РФ
+	Records []dynamicRecord `xml:"Record"`  // Курсы по дням
+}
+
+// dynamicRecord содержит курс валюты за один день из ответа XML_dynamic
+type dynamicRecord struct {
+	Date    string `xml:"Date,attr"` // Дата в формате ДД.ММ.ГГГГ
+	Nominal int    `xml:"Nominal"`   // Номинал валюты
+	Value   string `xml:"Value"`     // Значение курса валюты
+}
+
+// DynamicSource загружает динамику курса одной валюты ЦБ РФ за период одним запросом
+// и реализует RateSource. Ответ загружается при первом обращении и переиспользуется для всех дат.
+type DynamicSource struct {
+	URL      string       // Шаблон URL динамики курса (см. CBRDynamicURL)
+	Client   *http.Client // HTTP-клиент с таймаутом запроса; nil означает клиент по умолчанию
+	Header   http.Header  // Дополнительные заголовки запроса
+	CharCode string       // Символьный код валюты
+	ID       string       // Внутренний код валюты ЦБ РФ
+	Start    time.Time    // Начальная дата периода
+	End      time.Time    // Конечная дата периода включительно
+
+	mu   sync.Mutex
+	days map[string]ValCurs // Разобранные курсы по дате в формате ДД.ММ.ГГГГ
+}
+
+// RequestURL возвращает адрес запроса динамики курса за период
+func (s *DynamicSource) RequestURL() string {
+	return fmt.Sprintf(s.URL, s.Start.Format(dateReqLayout), s.End.Format(dateReqLayout), s.ID)
+}
+
+// BaseCurrency возвращает рубль: ЦБ РФ публикует курсы валют в рублях
+func (s *DynamicSource) BaseCurrency() string {
+	return BaseRUB
+}
+
+// FetchRates возвращает курс валюты ЦБ РФ за указанную дату. Если курс на дату
+// не устанавливался, возвращается ошибка ErrStaleDate.
+func (s *DynamicSource) FetchRates(ctx context.Context, date time.Time) (ValCurs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.days == nil {
+		data, err := FetchCurrencyRates(ctx, clientOrDefault(s.Client), s.RequestURL(), s.Header)
+		if err != nil {
+			return ValCurs{}, fmt.Errorf("Ошибка при загрузке динамики курса %s: %w", s.CharCode, err)
+		}
+		days, err := parseDynamic(data, s.CharCode)
+		if err != nil {
+			return ValCurs{}, fmt.Errorf("Ошибка при разборе динамики курса %s: %w", s.CharCode, err)
+		}
+		for date, valCurs := range days {
+			valCurs.Origin, valCurs.Size = OriginNetwork, len(data)
+			days[date] = valCurs
+		}
+		s.days = days
+	}
+
+	valCurs, ok := s.days[date.Format(valCursDateLayout)]
+	if !ok {
+		return ValCurs{}, fmt.Errorf("Нет курса %s за %s: %w", s.CharCode, date.Format(isoDateLayout), ErrStaleDate)
+	}
+	valCurs.Request = date
+	return valCurs, nil
+}
+
+// parseDynamic разбирает ответ XML_dynamic ЦБ РФ и возвращает курсы валюты code
+// по дате в формате ДД.ММ.ГГГГ
+func parseDynamic(data, code string) (map[string]ValCurs, error) {
+	data, enc, err := toUTF8(data)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка кодировки XML: %w", err)
+	}
+
+	var envelope dynamicEnvelope
+	decoder := newUTF8Decoder(data)
+	if err := decoder.Decode(&envelope); err != nil {
+		offset := decoder.InputOffset()
+		return nil, &ParseError{Offset: sourceOffset(data, enc, offset), Context: errorContext(data, offset), Err: err}
+	}
+
+	code = strings.ToUpper(code)
+	days := make(map[string]ValCurs, len(envelope.Records))
+	for _, record := range envelope.Records {
+		date, err := time.Parse(valCursDateLayout, record.Date)
+		if err != nil {
+			return nil, fmt.Errorf("Некорректная дата динамики курса %q: %w", record.Date, err)
+		}
+
+		days[record.Date] = ValCurs{
+			Date: record.Date,
+			Time: date,
+			Valutes: []Valute{{
+				ID:       envelope.ID,
+				NumCode:  currencyNumCodes[code],
+				CharCode: code,
+				Nominal:  record.Nominal,
+				Name:     code,
+				Value:    record.Value,
+			}},
+		}
+	}
+	return days, nil
+}
@@ -0,0 +1,100 @@
+package exchangerates
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseDynamic(t *testing.T) {
+	days, err := parseDynamic(readTestdata(t, "XML_dynamic.xml"), "usd")
+	if err != nil {
+		t.Fatalf("parseDynamic: %v", err)
+	}
+	if len(days) != 5 {
+		t.Fatalf("len(days) = %d, want 5", len(days))
+	}
+
+	day, ok := days["02.02.2024"]
+	if !ok {
+		t.Fatal("нет курса за 02.02.2024")
+	}
+	if !day.Time.Equal(time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)) || day.BaseCurrency() != BaseRUB {
+		t.Errorf("Time = %v, BaseCurrency() = %q", day.Time, day.BaseCurrency())
+	}
+	if len(day.Valutes) != 1 {
+		t.Fatalf("len(Valutes) = %d, want 1", len(day.Valutes))
+	}
+	usd := day.Valutes[0]
+	if usd.ID != "R01235" || usd.CharCode != "USD" || usd.NumCode != "840" || usd.Nominal != 1 {
+		t.Errorf("USD = %+v", usd)
+	}
+	if value, err := usd.FloatValue(); err != nil || value != 90.2826 {
+		t.Errorf("USD = %v, %v, want 90.2826", value, err)
+	}
+	if _, ok := days["05.02.2024"]; ok {
+		t.Error("курс за 05.02.2024 отсутствует в ответе")
+	}
+}
+
+func TestParseDynamicInvalidDate(t *testing.T) {
+	data := `<ValCurs ID="R01235"><Record Date="2024-02-01"><Nominal>1</Nominal><Value>89,2887</Value></Record></ValCurs>`
+	if _, err := parseDynamic(data, "USD"); err == nil {
+		t.Fatal("ожидалась ошибка для некорректной даты")
+	}
+}
+
+func TestDynamicSourceFetchRates(t *testing.T) {
+	var requests int
+	var query string
+	data := readTestdata(t, "XML_dynamic.xml")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		query = r.URL.RawQuery
+		w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	id, err := CBRCurrencyID("usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &DynamicSource{
+		URL:      server.URL + "/scripts/XML_dynamic.asp?date_req1=%s&date_req2=%s&VAL_NM_RQ=%s",
+		Client:   server.Client(),
+		CharCode: "USD",
+		ID:       id,
+		Start:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		End:      time.Date(2024, 2, 7, 0, 0, 0, 0, time.UTC),
+	}
+
+	date := time.Date(2024, 2, 6, 0, 0, 0, 0, time.UTC)
+	valCurs, err := source.FetchRates(context.Background(), date)
+	if err != nil {
+		t.Fatalf("FetchRates: %v", err)
+	}
+	if valCurs.Date != "06.02.2024" || !valCurs.Request.Equal(date) || valCurs.Origin != OriginNetwork {
+		t.Errorf("valCurs = %+v", valCurs)
+	}
+	if want := "date_req1=01/02/2024&date_req2=07/02/2024&VAL_NM_RQ=R01235"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+
+	// Динамика загружается одним запросом за весь период
+	if _, err := source.FetchRates(context.Background(), date.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("FetchRates: %v", err)
+	}
+	if _, err := source.FetchRates(context.Background(), date.AddDate(0, 0, -1)); !errors.Is(err, ErrStaleDate) {
+		t.Errorf("FetchRates(05.02.2024): err = %v, want ErrStaleDate", err)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+
+	if _, err := CBRCurrencyID("XYZ"); err == nil {
+		t.Error("CBRCurrencyID(XYZ): ожидалась ошибка")
+	}
+}
@@ -0,0 +1,132 @@
+package exchangerates
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultECBURL задаёт адрес ленты ЕЦБ с историей курсов евро за последние 90 дней
+const DefaultECBURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
+
+// ecbEnvelope представляет корневой элемент XML-ленты ЕЦБ
+type ecbEnvelope struct {
+	Days []ecbDay `xml:"Cube>Cube"` // Курсы по дням
+}
+
+// ecbDay содержит курсы валют ЕЦБ за один день
+type ecbDay struct {
+	Time  string    `xml:"time,attr"` // Дата в формате ГГГГ-ММ-ДД
+	Rates []ecbRate `xml:"Cube"`      // Курсы валют к евро
+}
+
+// ecbRate содержит курс одной валюты ЕЦБ: количество единиц валюты за один евро
+type ecbRate struct {
+	Currency string `xml:"currency,attr"` // Символьный код валюты
+	Rate     string `xml:"rate,attr"`     // Количество единиц валюты за один евро
+}
+
+// ECBSource загружает курсы валют из ленты Европейского центрального банка и реализует RateSource.
+// Лента содержит историю за период, поэтому загружается один раз и переиспользуется для всех дат.
+// В отличие от ЦБ РФ, значения курсов выражены в евро за единицу валюты.
+type ECBSource struct {
+	URL    string       // Адрес XML-ленты ЕЦБ
+	Client *http.Client // HTTP-клиент с таймаутом запроса; nil означает клиент по умолчанию
+	Header http.Header  // Дополнительные заголовки запроса
+
+	mu   sync.Mutex
+	days map[string]ValCurs // Разобранные курсы по дате в формате ГГГГ-ММ-ДД
+	err  error              // Ошибка загрузки ленты, возвращаемая для всех последующих дат
+}
+
+// BaseCurrency возвращает евро: ЕЦБ публикует курсы валют к евро
+func (e *ECBSource) BaseCurrency() string {
+	return BaseEUR
+}
+
+// FetchRates возвращает курсы валют ЕЦБ за указанную дату
+func (e *ECBSource) FetchRates(ctx context.Context, date time.Time) (ValCurs, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err != nil {
+		return ValCurs{}, e.err
+	}
+	if e.days == nil {
+		days, err := e.load(ctx)
+		if err != nil {
+			// Отмена контекста относится к одному запросу, поэтому лента будет загружена повторно
+			if ctx.Err() == nil {
+				e.err = err
+			}
+			return ValCurs{}, err
+		}
+		e.days = days
+	}
+
+	valCurs, ok := e.days[date.Format(isoDateLayout)]
+	if !ok {
+		return ValCurs{}, fmt.Errorf("Нет курсов ЕЦБ за %s: %w", date.Format(isoDateLayout), ErrStaleDate)
+	}
+	valCurs.Request = date
+	return valCurs, nil
+}
+
+// load загружает и разбирает ленту ЕЦБ
+func (e *ECBSource) load(ctx context.Context) (map[string]ValCurs, error) {
+	data, err := FetchCurrencyRates(ctx, clientOrDefault(e.Client), e.URL, e.Header)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при загрузке курсов ЕЦБ: %w", err)
+	}
+	days, err := parseECB(data)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при разборе XML ЕЦБ: %w", err)
+	}
+	for date, valCurs := range days {
+		valCurs.Origin, valCurs.Size = OriginNetwork, len(data)
+		days[date] = valCurs
+	}
+	return days, nil
+}
+
+// parseECB разбирает XML-ленту ЕЦБ и возвращает курсы по дате в формате ГГГГ-ММ-ДД.
+// Курсы пересчитываются в евро за единицу валюты, чтобы соответствовать смыслу курсов ЦБ РФ.
+func parseECB(data string) (map[string]ValCurs, error) {
+	var envelope ecbEnvelope
+	decoder := xml.NewDecoder(bytes.NewReader([]byte(data)))
+	if err := decoder.Decode(&envelope); err != nil {
+		offset := decoder.InputOffset()
+		return nil, &ParseError{Offset: offset, Context: errorContext(data, offset), Err: err}
+	}
+
+	days := make(map[string]ValCurs, len(envelope.Days))
+	for _, day := range envelope.Days {
+		date, err := time.Parse(isoDateLayout, day.Time)
+		if err != nil {
+			return nil, fmt.Errorf("Некорректная дата в ленте ЕЦБ %q: %w", day.Time, err)
+		}
+
+		valCurs := ValCurs{Date: date.Format(valCursDateLayout), Time: date, Base: BaseEUR}
+		for _, rate := range day.Rates {
+			perEuro, err := strconv.ParseFloat(rate.Rate, 64)
+			if err != nil || perEuro <= 0 {
+				return nil, fmt.Errorf("Некорректный курс ЕЦБ для валюты %s: %q", rate.Currency, rate.Rate)
+			}
+			valCurs.Valutes = append(valCurs.Valutes, Valute{
+				ID:       rate.Currency,
+				NumCode:  currencyNumCodes[rate.Currency],
+				CharCode: rate.Currency,
+				Nominal:  1,
+				Name:     rate.Currency,
+				Value:    strconv.FormatFloat(1/perEuro, 'f', -1, 64),
+			})
+		}
+		days[day.Time] = valCurs
+	}
+	return days, nil
+}
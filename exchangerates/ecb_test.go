@@ -0,0 +1,154 @@
+package exchangerates
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// readTestdata возвращает содержимое файла из каталога testdata
+func readTestdata(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseECB(t *testing.T) {
+	days, err := parseECB(readTestdata(t, "eurofxref-hist.xml"))
+	if err != nil {
+		t.Fatalf("parseECB: %v", err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("len(days) = %d, want 2", len(days))
+	}
+
+	day, ok := days["2024-02-01"]
+	if !ok {
+		t.Fatal("нет курсов за 2024-02-01")
+	}
+	if day.Date != "01.02.2024" || !day.Time.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Date = %q, Time = %v", day.Date, day.Time)
+	}
+	if day.BaseCurrency() != BaseEUR {
+		t.Errorf("BaseCurrency() = %q, want %q", day.BaseCurrency(), BaseEUR)
+	}
+	if len(day.Valutes) != 3 {
+		t.Fatalf("len(Valutes) = %d, want 3", len(day.Valutes))
+	}
+
+	usd := day.Valutes[0]
+	if usd.CharCode != "USD" || usd.NumCode != "840" || usd.Nominal != 1 {
+		t.Errorf("USD = %+v", usd)
+	}
+	value, err := usd.FloatValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Лента ЕЦБ содержит единицы валюты за евро, в ValCurs — евро за единицу валюты
+	if want := 1 / 1.0814; math.Abs(value-want) > 1e-12 {
+		t.Errorf("USD = %v, want %v", value, want)
+	}
+}
+
+func TestParseECBInvalidRate(t *testing.T) {
+	data := `<Envelope><Cube><Cube time="2024-02-01"><Cube currency="USD" rate="0"/></Cube></Cube></Envelope>`
+	if _, err := parseECB(data); err == nil {
+		t.Fatal("ожидалась ошибка для нулевого курса")
+	}
+}
+
+func TestECBSourceFetchRates(t *testing.T) {
+	var requests int
+	data := readTestdata(t, "eurofxref-hist.xml")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	source := &ECBSource{URL: server.URL, Client: server.Client()}
+	if source.BaseCurrency() != BaseEUR {
+		t.Errorf("BaseCurrency() = %q, want %q", source.BaseCurrency(), BaseEUR)
+	}
+
+	date := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	valCurs, err := source.FetchRates(context.Background(), date)
+	if err != nil {
+		t.Fatalf("FetchRates: %v", err)
+	}
+	if valCurs.Date != "02.02.2024" || !valCurs.Request.Equal(date) || valCurs.Origin != OriginNetwork {
+		t.Errorf("valCurs = %+v", valCurs)
+	}
+
+	// Лента загружается один раз для всех дат
+	if _, err := source.FetchRates(context.Background(), date.AddDate(0, 0, -1)); err != nil {
+		t.Fatalf("FetchRates: %v", err)
+	}
+	if _, err := source.FetchRates(context.Background(), date.AddDate(0, 0, 1)); !errors.Is(err, ErrStaleDate) {
+		t.Errorf("FetchRates для даты вне ленты: %v, want ErrStaleDate", err)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+}
+
+func TestECBSourceRemembersFailedFeed(t *testing.T) {
+	var calls atomic.Int32
+	server := failingServer(t, 1, http.StatusInternalServerError, readTestdata(t, "eurofxref-hist.xml"), &calls)
+	source := &ECBSource{URL: server.URL, Client: server.Client()}
+
+	// Ошибка загрузки запоминается, и следующие даты не обращаются к серверу повторно
+	date := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	_, first := source.FetchRates(context.Background(), date)
+	_, second := source.FetchRates(context.Background(), date.AddDate(0, 0, -1))
+	var statusErr *StatusError
+	if !errors.As(first, &statusErr) || second == nil || second.Error() != first.Error() {
+		t.Errorf("ошибки = %v, %v, want одну и ту же ошибку статуса", first, second)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+
+	// Отменённый запрос не запоминается
+	cancelled := &ECBSource{URL: server.URL, Client: server.Client()}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cancelled.FetchRates(ctx, date); err == nil {
+		t.Fatal("ожидалась ошибка отменённого запроса")
+	}
+	if _, err := cancelled.FetchRates(context.Background(), date); err != nil {
+		t.Errorf("FetchRates после отмены: %v", err)
+	}
+}
+
+func TestConvertECB(t *testing.T) {
+	days, err := parseECB(readTestdata(t, "eurofxref-hist.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStatsStore()
+	store.Update(days["2024-02-01"])
+
+	if store.Base() != BaseEUR {
+		t.Errorf("Base() = %q, want %q", store.Base(), BaseEUR)
+	}
+	got, err := store.Convert(100, "USD", "EUR")
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if want := 100 / 1.0814; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Convert(100, USD, EUR) = %v, want %v", got, want)
+	}
+	if _, err := store.Convert(100, "USD", "RUB"); err == nil {
+		t.Error("ожидалась ошибка: рубля нет в курсах ЕЦБ")
+	}
+}
@@ -0,0 +1,472 @@
+package exchangerates
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultTimeout задаёт таймаут HTTP-запроса к API по умолчанию
+const DefaultTimeout = 10 * time.Second
+
+// DefaultDayTimeout задаёт по умолчанию ограничение времени загрузки курсов за один день
+// с учётом повторных попыток
+const DefaultDayTimeout = time.Minute
+
+// Параметры пула соединений HTTP-клиента по умолчанию
+const (
+	maxIdleConns        = 16               // Максимальное количество простаивающих соединений
+	idleConnTimeout     = 90 * time.Second // Время жизни простаивающего соединения
+	tlsHandshakeTimeout = 5 * time.Second  // Таймаут установки TLS-соединения
+)
+
+// NewHTTPClient создаёт HTTP-клиент с заданным таймаутом запроса и пулом соединений,
+// который переиспользуется всеми запросами к API. Запросы направляются через прокси proxyURL
+// (http, https или socks5); при nil прокси берётся из переменных окружения HTTP_PROXY, HTTPS_PROXY и NO_PROXY.
+// При insecureSkipVerify сертификаты HTTPS не проверяются: это допустимо только для отладки
+// за прокси, подменяющими TLS.
+func NewHTTPClient(timeout time.Duration, proxyURL *url.URL, insecureSkipVerify bool) *http.Client {
+	proxy := http.ProxyFromEnvironment
+	if proxyURL != nil {
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := &http.Transport{
+		Proxy:               proxy,
+		MaxIdleConns:        maxIdleConns,
+		MaxIdleConnsPerHost: maxIdleConns,
+		IdleConnTimeout:     idleConnTimeout,
+		TLSHandshakeTimeout: tlsHandshakeTimeout,
+	}
+	if insecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// defaultClient используется источниками курсов, для которых HTTP-клиент не задан
+var defaultClient = NewHTTPClient(DefaultTimeout, nil, false)
+
+// clientOrDefault возвращает client или клиент по умолчанию, если client не задан
+func clientOrDefault(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return defaultClient
+}
+
+// maxResponseSize задаёт максимальный допустимый размер ответа API в байтах
+const maxResponseSize = 5 << 20
+
+// errorSnippetSize задаёт максимальный размер фрагмента тела ответа в сообщении об ошибке
+const errorSnippetSize = 200
+
+// DefaultMaxRetries задаёт количество повторных попыток запроса по умолчанию
+const DefaultMaxRetries = 3
+
+// DefaultParseRetries задаёт по умолчанию количество повторных загрузок ответа, который не удалось разобрать
+const DefaultParseRetries = 1
+
+// DefaultRetryDelay задаёт базовую задержку перед повторной попыткой запроса по умолчанию
+const DefaultRetryDelay = 500 * time.Millisecond
+
+// DefaultConcurrency задаёт количество параллельных запросов к API по умолчанию
+const DefaultConcurrency = 8
+
+// CBRDailyURL задаёт шаблон URL ежедневных курсов ЦБ РФ с названиями валют на русском языке
+const CBRDailyURL = "http://www.cbr.ru/scripts/XML_daily.asp?date_req=%s"
+
+// CBRDailyEngURL задаёт шаблон URL ежедневных курсов ЦБ РФ с названиями валют на английском языке
+const CBRDailyEngURL = "http://www.cbr.ru/scripts/XML_daily_eng.asp?date_req=%s"
+
+// DefaultUserAgent задаёт заголовок User-Agent запросов к API по умолчанию
+const DefaultUserAgent = "exchange-rates/1.0"
+
+// dateReqLayout задаёт формат даты для параметра date_req API ЦБ РФ (дд/мм/гггг)
+const dateReqLayout = "02/01/2006"
+
+// FetchCurrencyRates выполняет запрос к API ЦБ РФ и возвращает XML с данными о курсах валют.
+// Запрос выполняется клиентом client, что позволяет переиспользовать соединения между запросами.
+// Заголовки header добавляются к запросу и могут переопределить User-Agent по умолчанию.
+// Запрос прерывается при отмене ctx.
+func FetchCurrencyRates(ctx context.Context, client *http.Client, url string, header http.Header) (string, error) {
+	data, _, err := fetchResponse(ctx, client, url, header)
+	return data, err
+}
+
+// errNotModified возвращается fetchResponse, если сервер ответил 304 Not Modified
+// на условный запрос и сохранённый ответ можно использовать повторно
+var errNotModified = errors.New("ответ API не изменился")
+
+// fetchResponse выполняет запрос к API так же, как FetchCurrencyRates, и дополнительно
+// возвращает заголовки ответа. На ответ 304 Not Modified возвращается errNotModified.
+func fetchResponse(ctx context.Context, client *http.Client, url string, header http.Header) (data string, respHeader http.Header, err error) {
+	fetchAttempts.Inc()
+	defer func() {
+		if err != nil && !errors.Is(err, errNotModified) {
+			fetchFailures.Inc()
+		} else {
+			fetchSuccesses.Inc()
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("Ошибка при создании запроса: %w", err)
+	}
+
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	for name, values := range header {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+
+	slog.Debug("Запрос к API", "url", url)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if os.IsTimeout(err) {
+			return "", nil, fmt.Errorf("Превышено время ожидания ответа API (%s): %w", client.Timeout, err)
+		}
+		return "", nil, fmt.Errorf("Ошибка при запросе к API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		slog.Debug("Ответ API не изменился", "url", url)
+		return "", resp.Header, errNotModified
+	}
+
+	reader, err := decodeBody(resp)
+	if err != nil {
+		return "", nil, err
+	}
+	defer reader.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(reader, errorSnippetSize))
+		return "", nil, &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(snippet))}
+	}
+
+	// Ограничение применяется к распакованному ответу
+	body, err := io.ReadAll(io.LimitReader(reader, maxResponseSize+1))
+	if err != nil {
+		return "", nil, fmt.Errorf("Ошибка при чтении ответа: %w", err)
+	}
+	if len(body) > maxResponseSize {
+		return "", nil, fmt.Errorf("Размер ответа API превышает допустимые %d байт", maxResponseSize)
+	}
+
+	slog.Debug("Получен ответ API", "url", url, "size", len(body))
+
+	return string(body), resp.Header, nil
+}
+
+// decodeBody возвращает тело ответа, распакованное согласно заголовку Content-Encoding
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return io.NopCloser(resp.Body), nil
+	case "gzip":
+		r, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Ошибка при распаковке ответа gzip: %w", err)
+		}
+		return r, nil
+	case "deflate":
+		r, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Ошибка при распаковке ответа deflate: %w", err)
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("Неподдерживаемое сжатие ответа: %s", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+// StatusError описывает ответ API с неуспешным HTTP-статусом
+type StatusError struct {
+	StatusCode int    // HTTP-статус ответа
+	Body       string // Начало тела ответа для диагностики
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("API вернул статус %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// isRetryable определяет, имеет ли смысл повторить запрос после ошибки:
+// повторяются сетевые ошибки, ответы 429 и 5xx
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoffDelay возвращает задержку перед повторной попыткой attempt (начиная с 0):
+// экспоненциально растущую от base со случайной добавкой до половины задержки.
+// Добавка выбирается функцией int63n с семантикой rand.Int63n.
+func backoffDelay(base time.Duration, attempt int, int63n func(int64) int64) time.Duration {
+	delay := base << attempt
+	if delay <= 0 {
+		return 0
+	}
+	return delay + time.Duration(int63n(int64(delay)/2+1))
+}
+
+// Fetcher загружает курсы валют из API ЦБ РФ и реализует RateSource
+type Fetcher struct {
+	BaseURL      string        // Шаблон URL запроса с параметром даты
+	Client       *http.Client  // HTTP-клиент с таймаутом запроса; nil означает клиент по умолчанию
+	MaxRetries   int           // Максимальное количество повторных попыток запроса
+	ParseRetries int           // Количество повторных загрузок ответа, который не удалось разобрать
+	RetryDelay   time.Duration // Базовая задержка перед повторной попыткой
+	CacheDir     string        // Каталог файлового кэша ответов; пустая строка отключает кэш
+	CacheTTL     time.Duration // Срок хранения в кэше ответов за текущую дату; 0 — бессрочно
+	SkipStale    bool          // Отклонять ответы, дата которых не совпадает с запрошенной
+	Header       http.Header   // Дополнительные заголовки запроса (например, User-Agent или ключ API)
+	Limiter      *rate.Limiter // Ограничение частоты запросов к API; nil отключает ограничение
+	Revalidate   bool          // Проверять устаревшие ответы за текущую дату условными запросами (ETag/Last-Modified)
+	Budget       *RetryBudget  // Общий для всех дат запас повторных попыток; nil отключает ограничение
+	Parsed       *ParsedCache  // Кэш разобранных курсов в памяти; nil отключает кэш
+
+	// Jitter задаёт источник случайной добавки к задержке перед повторной попыткой;
+	// nil означает общий генератор math/rand. Фиксированный источник делает задержки воспроизводимыми.
+	Jitter rand.Source
+	// After ожидает задержку перед повторной попыткой; nil означает time.After.
+	// Позволяет подменить часы, чтобы не ждать реальное время.
+	After func(time.Duration) <-chan time.Time
+
+	jitterMu sync.Mutex // Защищает Jitter, который не безопасен для одновременного использования
+}
+
+// int63n возвращает случайное число в [0, n) из источника Jitter или общего генератора
+func (f *Fetcher) int63n(n int64) int64 {
+	if f.Jitter == nil {
+		return rand.Int63n(n)
+	}
+	f.jitterMu.Lock()
+	defer f.jitterMu.Unlock()
+	return rand.New(f.Jitter).Int63n(n)
+}
+
+// after возвращает канал, в который придёт значение через d (см. Fetcher.After)
+func (f *Fetcher) after(d time.Duration) <-chan time.Time {
+	if f.After == nil {
+		return time.After(d)
+	}
+	return f.After(d)
+}
+
+// RetryBudget ограничивает общее количество повторных попыток запросов за всю загрузку,
+// чтобы при сбоях API повторы по каждому дню не умножались на количество дней.
+// Безопасен для одновременного использования из нескольких горутин.
+type RetryBudget struct {
+	remaining atomic.Int64
+}
+
+// NewRetryBudget создаёт запас из n повторных попыток
+func NewRetryBudget(n int) *RetryBudget {
+	b := &RetryBudget{}
+	b.remaining.Store(int64(n))
+	return b
+}
+
+// take расходует одну повторную попытку. Возвращает false, если запас исчерпан.
+func (b *RetryBudget) take() bool {
+	return b.remaining.Add(-1) >= 0
+}
+
+// ErrStaleDate возвращается, если ЦБ РФ не публиковал курсы на запрошенную дату
+// и вернул курсы за предыдущий рабочий день
+var ErrStaleDate = errors.New("курсы на запрошенную дату не публиковались")
+
+// ErrNoRates возвращается, если ответ ЦБ РФ разобран, но не содержит ни одной валюты
+var ErrNoRates = errors.New("в ответе нет курсов валют")
+
+// fetchWithRetry выполняет запрос к API с дополнительными заголовками conditional
+// (например, If-None-Match), повторяя его при временных ошибках не более MaxRetries раз
+// с экспоненциальной задержкой. Возвращает тело и заголовки ответа.
+func (f *Fetcher) fetchWithRetry(ctx context.Context, url string, conditional http.Header) (string, http.Header, error) {
+	header := f.Header
+	if len(conditional) > 0 {
+		header = f.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		for name, values := range conditional {
+			header[name] = values
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		// Ограничитель общий для всех параллельных запросов и учитывает повторные попытки
+		if f.Limiter != nil {
+			if err := f.Limiter.Wait(ctx); err != nil {
+				return "", nil, fmt.Errorf("Ошибка при ожидании ограничителя запросов: %w", err)
+			}
+		}
+
+		data, respHeader, err := fetchResponse(ctx, clientOrDefault(f.Client), url, header)
+		if err == nil || attempt >= f.MaxRetries || !isRetryable(err) {
+			return data, respHeader, err
+		}
+		if f.Budget != nil && !f.Budget.take() {
+			slog.Debug("Общий запас повторных попыток исчерпан", "url", url)
+			return data, respHeader, err
+		}
+
+		select {
+		case <-f.after(backoffDelay(f.RetryDelay, attempt, f.int63n)):
+		case <-ctx.Done():
+			return "", nil, fmt.Errorf("Ошибка при запросе к API: %w", ctx.Err())
+		}
+	}
+}
+
+// URL возвращает адрес запроса курсов валют ЦБ РФ за дату d
+func (f *Fetcher) URL(d time.Time) string {
+	return fmt.Sprintf(f.BaseURL, d.Format(dateReqLayout))
+}
+
+// BaseCurrency возвращает рубль: ЦБ РФ публикует курсы валют в рублях
+func (f *Fetcher) BaseCurrency() string {
+	return BaseRUB
+}
+
+// FetchRates загружает и разбирает курсы валют ЦБ РФ за одну дату.
+// Если задан Parsed, ранее разобранные курсы берутся из него без загрузки и разбора.
+func (f *Fetcher) FetchRates(ctx context.Context, d time.Time) (ValCurs, error) {
+	valCurs, ok := f.Parsed.get(d)
+	if !ok {
+		var err error
+		valCurs, err = f.load(ctx, d)
+		if err != nil {
+			return ValCurs{}, err
+		}
+		f.Parsed.add(d, valCurs)
+	}
+
+	if f.SkipStale && !valCurs.MatchesRequest() {
+		return ValCurs{}, fmt.Errorf("Пропуск даты %s, получены курсы за %s: %w", d.Format(dateReqLayout), valCurs.Date, ErrStaleDate)
+	}
+	return valCurs, nil
+}
+
+// load загружает курсы за дату d из файлового кэша или API и разбирает их
+func (f *Fetcher) load(ctx context.Context, d time.Time) (ValCurs, error) {
+	dateStr := d.Format(dateReqLayout) // Форматирование даты для запроса
+	url := f.URL(d)
+
+	xmlData, state := "", cacheMissing
+	if f.CacheDir != "" {
+		xmlData, state = readCache(f.CacheDir, d, f.CacheTTL, time.Now())
+	}
+	cached := state == cacheFresh
+
+	// Прошедшие даты берутся из кэша без обращения к API, а устаревший ответ за текущую дату
+	// проверяется условным запросом, если сервер прислал ETag или Last-Modified
+	var respHeader http.Header
+	if state == cacheStale && f.Revalidate {
+		if meta := readCacheMeta(f.CacheDir, d); meta.conditional() != nil {
+			data, h, err := f.fetchWithRetry(ctx, url, meta.conditional())
+			switch {
+			case errors.Is(err, errNotModified):
+				// Сохранённый ответ актуален
+				cached = true
+				if err := touchCache(f.CacheDir, d, time.Now()); err != nil {
+					slog.Warn("Не удалось обновить время ответа в кэше", "date", dateStr, "error", err)
+				}
+			case err != nil:
+				slog.Warn("Не удалось проверить актуальность кэша, используется сохранённый ответ", "date", dateStr, "error", err)
+				cached = true
+			default:
+				xmlData, respHeader = data, h
+			}
+		}
+	}
+
+	var valCurs ValCurs
+	parseRetries := f.ParseRetries
+	for attempt := 0; ; attempt++ {
+		if !cached && respHeader == nil {
+			var err error
+			xmlData, respHeader, err = f.fetchWithRetry(ctx, url, nil) // Получение данных о курсах валют
+			if err != nil {
+				return ValCurs{}, fmt.Errorf("Ошибка при загрузке курсов за %s: %w", dateStr, err)
+			}
+		}
+
+		var err error
+		valCurs, err = ParseXML(xmlData) // Разбор полученных данных
+		if err == nil {
+			break
+		}
+		if cached {
+			// Файл кэша мог остаться обрезанным после прерванной записи, поэтому ответ
+			// загружается заново без расхода повторных попыток и перезаписывается в кэше
+			slog.Warn("Не удалось разобрать ответ из кэша, курсы загружаются заново", "date", dateStr, "error", err)
+			cached, respHeader = false, nil
+			parseRetries++
+			continue
+		}
+		// Ответ мог прийти обрезанным, поэтому он загружается заново
+		if attempt >= parseRetries || ctx.Err() != nil {
+			return ValCurs{}, fmt.Errorf("Ошибка при разборе XML для даты %s: %w", dateStr, err)
+		}
+		slog.Warn("Повторная загрузка курсов после ошибки разбора", "date", dateStr, "attempt", attempt+1, "error", err)
+		respHeader = nil
+	}
+	// Некорректные валюты пропускаются при расчёте статистики, поэтому нарушения только журналируются
+	if err := valCurs.Validate(); err != nil {
+		slog.Warn("Ответ ЦБ РФ не прошёл проверку", "date", dateStr, "error", err)
+	}
+	// Пустой ответ не кэшируется: данные за дату могут появиться позже
+	if len(valCurs.Valutes) == 0 {
+		return ValCurs{}, fmt.Errorf("Пропуск даты %s: %w", dateStr, ErrNoRates)
+	}
+
+	// В кэш попадают только успешно разобранные ответы
+	if !cached && f.CacheDir != "" {
+		if err := writeCache(f.CacheDir, d, xmlData); err != nil {
+			slog.Warn("Не удалось сохранить ответ в кэш", "error", err)
+		}
+		if err := writeCacheMeta(f.CacheDir, d, newCacheMeta(respHeader)); err != nil {
+			slog.Warn("Не удалось сохранить заголовки ответа в кэш", "error", err)
+		}
+	}
+
+	valCurs.Origin, valCurs.Size = OriginNetwork, len(xmlData)
+	valCurs.Request = d
+	if cached {
+		valCurs.Origin = OriginCache
+	}
+	return valCurs, nil
+}
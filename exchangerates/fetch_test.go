@@ -0,0 +1,628 @@
+package exchangerates
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestFetcherURL(t *testing.T) {
+	f := &Fetcher{BaseURL: CBRDailyURL}
+	tests := []struct {
+		date time.Time
+		want string
+	}{
+		{time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), "http://www.cbr.ru/scripts/XML_daily.asp?date_req=08/03/2024"},
+		{time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), "http://www.cbr.ru/scripts/XML_daily.asp?date_req=31/12/2023"},
+		{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "http://www.cbr.ru/scripts/XML_daily.asp?date_req=02/01/2024"},
+	}
+	for _, tt := range tests {
+		if got := f.URL(tt.date); got != tt.want {
+			t.Errorf("URL(%s) = %q, want %q", tt.date.Format(isoDateLayout), got, tt.want)
+		}
+	}
+}
+
+// slowHandler отвечает через delay или при отмене запроса клиентом
+func slowHandler(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+	}
+}
+
+func TestFetchCurrencyRatesTimeout(t *testing.T) {
+	server := httptest.NewServer(slowHandler(time.Second))
+	defer server.Close()
+
+	client := NewHTTPClient(50*time.Millisecond, nil, false)
+	_, err := FetchCurrencyRates(context.Background(), client, server.URL, nil)
+	if err == nil {
+		t.Fatal("ожидалась ошибка таймаута")
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() || !strings.Contains(err.Error(), "Превышено время ожидания") {
+		t.Errorf("err = %v, want timeout", err)
+	}
+}
+
+func TestFetchCurrencyRatesCanceled(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	_, err := FetchCurrencyRates(ctx, server.Client(), server.URL, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+// failingServer отвечает статусом status на первые failures запросов, а затем возвращает body.
+// Количество полученных запросов записывается в calls.
+func failingServer(t *testing.T, failures int, status int, body string, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= failures {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetcherRetriesTransientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := failingServer(t, 2, http.StatusServiceUnavailable, readTestdata(t, "XML_daily_eng.xml"), &calls)
+
+	f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", MaxRetries: 3, RetryDelay: time.Millisecond}
+	valCurs, err := f.FetchRates(context.Background(), time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+	if valCurs.Date != "02.02.2024" || len(valCurs.Valutes) == 0 {
+		t.Errorf("valCurs = %+v", valCurs)
+	}
+}
+
+func TestFetcherDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := failingServer(t, 1, http.StatusNotFound, readTestdata(t, "XML_daily_eng.xml"), &calls)
+
+	f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", MaxRetries: 3, RetryDelay: time.Millisecond}
+	_, err := f.FetchRates(context.Background(), time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("err = %v, want StatusError 404", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestFetcherGivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := failingServer(t, 10, http.StatusTooManyRequests, "", &calls)
+
+	f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", MaxRetries: 2, RetryDelay: time.Millisecond}
+	if _, err := f.FetchRates(context.Background(), time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Fatal("ожидалась ошибка")
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3 (запрос и две повторные попытки)", calls.Load())
+	}
+}
+
+func TestFetchCurrencyRatesStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>Internal error</html>", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := FetchCurrencyRates(context.Background(), server.Client(), server.URL, nil)
+	if err == nil {
+		t.Fatal("ожидалась ошибка")
+	}
+	if !strings.Contains(err.Error(), "500") || !strings.Contains(err.Error(), "Internal error") {
+		t.Errorf("err = %q, want статус 500 и начало тела ответа", err)
+	}
+}
+
+func TestFetchCurrencyRatesSizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", maxResponseSize+1)))
+	}))
+	defer server.Close()
+
+	_, err := FetchCurrencyRates(context.Background(), server.Client(), server.URL, nil)
+	if err == nil || !strings.Contains(err.Error(), "превышает") {
+		t.Errorf("err = %v, want ошибка превышения размера ответа", err)
+	}
+}
+
+func TestFetchCurrencyRatesAtSizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", maxResponseSize)))
+	}))
+	defer server.Close()
+
+	data, err := FetchCurrencyRates(context.Background(), server.Client(), server.URL, nil)
+	if err != nil || len(data) != maxResponseSize {
+		t.Errorf("len(data) = %d, err = %v, want %d и nil", len(data), err, maxResponseSize)
+	}
+}
+
+func TestFetcherRetryBudget(t *testing.T) {
+	const days, budget = 10, 5
+	dates := testDates(testDate(time.March, 1), days)
+	for _, tt := range []struct {
+		name   string
+		budget *RetryBudget
+		calls  int32
+	}{
+		{"без ограничения", nil, days * 4}, // Первая попытка и три повторные за каждый день
+		{"общий запас", NewRetryBudget(budget), days + budget},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := failingServer(t, math.MaxInt32, http.StatusServiceUnavailable, "", &calls)
+			f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", MaxRetries: 3, RetryDelay: time.Millisecond, Budget: tt.budget}
+
+			failed := 0
+			for result := range FetchAll(context.Background(), f, dates, 3, 0) {
+				var statusErr *StatusError
+				if !errors.As(result.Err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+					t.Errorf("%s: err = %v, want StatusError 503", result.Date.Format(isoDateLayout), result.Err)
+				}
+				failed++
+			}
+			if failed != days || calls.Load() != tt.calls {
+				t.Errorf("failed = %d, calls = %d, want %d и %d", failed, calls.Load(), days, tt.calls)
+			}
+		})
+	}
+}
+
+// truncatedServer возвращает обрезанный ответ на первые truncated запросов, а затем ответ целиком
+func truncatedServer(t *testing.T, truncated int, body string, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= truncated {
+			w.Write([]byte(body[:len(body)/2]))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetcherParseRetries(t *testing.T) {
+	body := readTestdata(t, "XML_daily_eng.xml")
+	date := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("повторная загрузка", func(t *testing.T) {
+		var calls atomic.Int32
+		server := truncatedServer(t, 1, body, &calls)
+		dir := t.TempDir()
+		f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", ParseRetries: 1, CacheDir: dir}
+		valCurs, err := f.FetchRates(context.Background(), date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls.Load() != 2 || valCurs.Date != "02.02.2024" || len(valCurs.Valutes) == 0 {
+			t.Errorf("calls = %d, valCurs = %+v, want 2 запроса и разобранные курсы", calls.Load(), valCurs)
+		}
+		// В кэш сохраняется только разобранный ответ
+		if cached, state := readCache(dir, date, 0, time.Now()); state != cacheFresh || cached != body {
+			t.Errorf("кэш содержит %d байт, want полный ответ %d байт", len(cached), len(body))
+		}
+	})
+
+	t.Run("без повторов", func(t *testing.T) {
+		var calls atomic.Int32
+		server := truncatedServer(t, 1, body, &calls)
+		f := &Fetcher{BaseURL: server.URL + "/?date_req=%s"}
+		_, err := f.FetchRates(context.Background(), date)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || calls.Load() != 1 {
+			t.Errorf("calls = %d, err = %v, want 1 запрос и ParseError", calls.Load(), err)
+		}
+	})
+}
+
+func TestNewHTTPClientInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(readTestdata(t, "XML_daily_eng.xml")))
+	}))
+	defer server.Close()
+	date := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+
+	// Сертификат тестового сервера не подписан доверенным центром сертификации
+	f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", Client: NewHTTPClient(time.Second, nil, false)}
+	_, err := f.FetchRates(context.Background(), date)
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) {
+		t.Errorf("err = %v, want ошибка проверки сертификата", err)
+	}
+
+	f.Client = NewHTTPClient(time.Second, nil, true)
+	valCurs, err := f.FetchRates(context.Background(), date)
+	if err != nil {
+		t.Fatalf("InsecureSkipVerify: %v", err)
+	}
+	if valCurs.Date != "02.02.2024" || len(valCurs.Valutes) == 0 {
+		t.Errorf("valCurs = %+v", valCurs)
+	}
+}
+
+func TestFetcherSeededBackoff(t *testing.T) {
+	var calls atomic.Int32
+	server := failingServer(t, math.MaxInt32, http.StatusServiceUnavailable, "", &calls)
+
+	// Задержки с фиксированным зерном: base << attempt плюс до половины этого значения
+	want := []time.Duration{119692967, 256385107, 442967209}
+	for run := 1; run <= 2; run++ {
+		var delays []time.Duration
+		f := &Fetcher{
+			BaseURL:    server.URL + "/?date_req=%s",
+			MaxRetries: 3,
+			RetryDelay: 100 * time.Millisecond,
+			Jitter:     rand.NewSource(42),
+			After: func(d time.Duration) <-chan time.Time {
+				delays = append(delays, d)
+				ch := make(chan time.Time, 1)
+				ch <- time.Time{} // Повтор выполняется сразу, без ожидания
+				return ch
+			},
+		}
+		if _, err := f.FetchRates(context.Background(), time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)); err == nil {
+			t.Fatal("ожидалась ошибка")
+		}
+		if !reflect.DeepEqual(delays, want) {
+			t.Errorf("run %d: delays = %v, want %v", run, delays, want)
+		}
+	}
+	if calls.Load() != 8 {
+		t.Errorf("calls = %d, want 8 (по четыре запроса за запуск)", calls.Load())
+	}
+}
+
+func TestFetcherCache(t *testing.T) {
+	var calls atomic.Int32
+	server := failingServer(t, 0, 0, readTestdata(t, "XML_daily_eng.xml"), &calls)
+	dir := t.TempDir()
+	date := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+
+	// Каждый запуск использует новый Fetcher, как отдельный запуск программы
+	for run, origin := range []string{OriginNetwork, OriginCache} {
+		f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", CacheDir: dir}
+		valCurs, err := f.FetchRates(context.Background(), date)
+		if err != nil {
+			t.Fatalf("run %d: %v", run+1, err)
+		}
+		if valCurs.Origin != origin || len(valCurs.Valutes) == 0 {
+			t.Errorf("run %d: Origin = %q, want %q", run+1, valCurs.Origin, origin)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1: повторный запуск не должен обращаться к серверу", calls.Load())
+	}
+}
+
+func TestFetcherRequestedDate(t *testing.T) {
+	// На субботу 03.02.2024 ЦБ РФ возвращает курсы за пятницу 02.02.2024
+	var calls atomic.Int32
+	server := failingServer(t, 0, 0, readTestdata(t, "XML_daily_eng.xml"), &calls)
+	requested := time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)
+	returned := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+
+	f := &Fetcher{BaseURL: server.URL + "/?date_req=%s"}
+	valCurs, err := f.FetchRates(context.Background(), requested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valCurs.Request.Equal(requested) || !valCurs.Time.Equal(returned) || valCurs.Date != "02.02.2024" {
+		t.Errorf("Request = %v, Time = %v, want %v и %v", valCurs.Request, valCurs.Time, requested, returned)
+	}
+	if valCurs.MatchesRequest() {
+		t.Error("MatchesRequest() = true, want false")
+	}
+
+	f = &Fetcher{BaseURL: server.URL + "/?date_req=%s", SkipStale: true}
+	if _, err := f.FetchRates(context.Background(), requested); !errors.Is(err, ErrStaleDate) {
+		t.Errorf("SkipStale: err = %v, want ErrStaleDate", err)
+	}
+	if valCurs, err := f.FetchRates(context.Background(), returned); err != nil || !valCurs.MatchesRequest() {
+		t.Errorf("SkipStale(%s): err = %v, MatchesRequest() = %v, want true", returned.Format(isoDateLayout), err, valCurs.MatchesRequest())
+	}
+}
+
+func TestFetcherRevalidateNotModified(t *testing.T) {
+	body := readTestdata(t, "XML_daily_eng.xml")
+	var calls, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	past := time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	fetch := func(d time.Time) ValCurs {
+		t.Helper()
+		f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", CacheDir: dir, CacheTTL: time.Hour, Revalidate: true}
+		valCurs, err := f.FetchRates(context.Background(), d)
+		if err != nil {
+			t.Fatalf("%s: %v", d.Format(isoDateLayout), err)
+		}
+		return valCurs
+	}
+
+	first := fetch(today)
+	fetch(past)
+	// Ответ за текущую дату устаревает на два часа больше ttl
+	old := now.Add(-2 * time.Hour)
+	if err := os.Chtimes(cachePath(dir, today), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// Устаревший ответ проверяется условным запросом: ответ 304 без тела,
+	// курсы разбираются из сохранённого ответа
+	valCurs := fetch(today)
+	if valCurs.Origin != OriginCache || valCurs.Date != first.Date || len(valCurs.Valutes) != len(first.Valutes) || valCurs.Size != first.Size {
+		t.Errorf("today: Origin = %q, Date = %s, valutes = %d, Size = %d, want %q, %s, %d, %d",
+			valCurs.Origin, valCurs.Date, len(valCurs.Valutes), valCurs.Size, OriginCache, first.Date, len(first.Valutes), first.Size)
+	}
+	if info, err := os.Stat(cachePath(dir, today)); err != nil || !info.ModTime().After(old) {
+		t.Errorf("время ответа в кэше не обновлено после 304: %v", err)
+	}
+	// Курсы за прошедшую дату не меняются и не проверяются
+	if valCurs := fetch(past); valCurs.Origin != OriginCache {
+		t.Errorf("past: Origin = %q, want %q", valCurs.Origin, OriginCache)
+	}
+	if calls.Load() != 3 || notModified.Load() != 1 {
+		t.Errorf("calls = %d, 304 = %d, want 3 и 1: условный запрос отправляется только для устаревшего ответа", calls.Load(), notModified.Load())
+	}
+}
+
+func TestFetcherRussianNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+		w.Write([]byte(readTestdata(t, "XML_daily.xml")))
+	}))
+	defer server.Close()
+
+	f := &Fetcher{BaseURL: server.URL + "/scripts/XML_daily.asp?date_req=%s"}
+	valCurs, err := f.FetchRates(context.Background(), time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]string)
+	for _, v := range valCurs.Valutes {
+		names[v.CharCode] = v.Name
+	}
+	if names["USD"] != "Доллар США" || names["EUR"] != "Евро" {
+		t.Errorf("names = %v, want USD Доллар США и EUR Евро", names)
+	}
+}
+
+// echoHeaderServer возвращает сервер, который отвечает JSON-объектом с заголовками запроса
+func echoHeaderServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(r.Header)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchCurrencyRatesHeaders(t *testing.T) {
+	server := echoHeaderServer(t)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   map[string]string
+	}{
+		{"по умолчанию", nil, map[string]string{"User-Agent": DefaultUserAgent}},
+		{
+			name:   "пользовательские заголовки",
+			header: http.Header{"User-Agent": {"rates-report/2.0 (ops@example.com)"}, "x-api-key": {"secret"}},
+			want:   map[string]string{"User-Agent": "rates-report/2.0 (ops@example.com)", "X-Api-Key": "secret"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := FetchCurrencyRates(context.Background(), server.Client(), server.URL, tt.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got http.Header
+			if err := json.Unmarshal([]byte(data), &got); err != nil {
+				t.Fatal(err)
+			}
+			for name, value := range tt.want {
+				if got.Get(name) != value {
+					t.Errorf("%s = %q, want %q", name, got.Get(name), value)
+				}
+			}
+		})
+	}
+}
+
+func TestFetcherRateLimit(t *testing.T) {
+	const requests, burst = 5, 2
+	var calls atomic.Int32
+	server := failingServer(t, 0, 0, readTestdata(t, "XML_daily_eng.xml"), &calls)
+
+	// Ограничение общее для всех параллельных загрузок: сверх запаса burst следующий запрос
+	// разрешён только через час, и Wait сразу возвращает ошибку, так как ожидание превысит срок контекста
+	f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", Limiter: rate.NewLimiter(rate.Every(time.Hour), burst)}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var succeeded, limited int
+	for result := range FetchAll(ctx, f, testDates(testDate(time.February, 1), requests), requests, 0) {
+		switch {
+		case result.Err == nil:
+			succeeded++
+		case strings.Contains(result.Err.Error(), "ограничителя запросов"):
+			limited++
+		default:
+			t.Errorf("%s: %v", result.Date.Format(isoDateLayout), result.Err)
+		}
+	}
+
+	if succeeded != burst || limited != requests-burst {
+		t.Errorf("succeeded = %d, limited = %d, want %d и %d", succeeded, limited, burst, requests-burst)
+	}
+	if calls.Load() != burst {
+		t.Errorf("calls = %d, want %d", calls.Load(), burst)
+	}
+}
+
+// connServer возвращает сервер, который записывает в conns адреса клиентских соединений
+func connServer(t testing.TB, conns *sync.Map) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conns.Store(r.RemoteAddr, true)
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPClientReusesConnections(t *testing.T) {
+	var conns sync.Map
+	server := connServer(t, &conns)
+	client := NewHTTPClient(DefaultTimeout, nil, false)
+
+	for i := 0; i < 5; i++ {
+		if _, err := FetchCurrencyRates(context.Background(), client, server.URL, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var n int
+	conns.Range(func(any, any) bool { n++; return true })
+	if n != 1 {
+		t.Errorf("соединений = %d, want 1: последовательные запросы должны использовать одно соединение", n)
+	}
+}
+
+func BenchmarkFetchNewClient(b *testing.B) {
+	var conns sync.Map
+	server := connServer(b, &conns)
+	for i := 0; i < b.N; i++ {
+		client := NewHTTPClient(DefaultTimeout, nil, false)
+		if _, err := FetchCurrencyRates(context.Background(), client, server.URL, nil); err != nil {
+			b.Fatal(err)
+		}
+		client.CloseIdleConnections()
+	}
+}
+
+func BenchmarkFetchSharedClient(b *testing.B) {
+	var conns sync.Map
+	server := connServer(b, &conns)
+	client := NewHTTPClient(DefaultTimeout, nil, false)
+	for i := 0; i < b.N; i++ {
+		if _, err := FetchCurrencyRates(context.Background(), client, server.URL, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFetchCurrencyRatesCompressed(t *testing.T) {
+	body := readTestdata(t, "XML_daily_eng.xml")
+	tests := []struct {
+		encoding string
+		compress func(io.Writer) io.WriteCloser
+	}{
+		{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), tt.encoding) {
+					t.Errorf("Accept-Encoding = %q, want %s", r.Header.Get("Accept-Encoding"), tt.encoding)
+				}
+				w.Header().Set("Content-Encoding", tt.encoding)
+				cw := tt.compress(w)
+				io.WriteString(cw, body)
+				cw.Close()
+			}))
+			defer server.Close()
+
+			data, err := FetchCurrencyRates(context.Background(), server.Client(), server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			valCurs, err := ParseXML(data)
+			if err != nil {
+				t.Fatalf("ParseXML: %v", err)
+			}
+			if valCurs.Date != "02.02.2024" || len(valCurs.Valutes) != 4 {
+				t.Errorf("valCurs = %+v", valCurs)
+			}
+		})
+	}
+}
+
+func TestFetcherCustomBaseURL(t *testing.T) {
+	var target atomic.Value
+	body := readTestdata(t, "XML_daily_eng.xml")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target.Store(r.URL.RequestURI())
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	f := &Fetcher{BaseURL: server.URL + "/mirror/cbr/XML_daily_eng.asp?date_req=%s"}
+	if _, err := f.FetchRates(context.Background(), time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if got := target.Load(); got != "/mirror/cbr/XML_daily_eng.asp?date_req=02/02/2024" {
+		t.Errorf("request target = %v", got)
+	}
+}
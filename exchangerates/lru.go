@@ -0,0 +1,75 @@
+package exchangerates
+
+import (
+	"container/list"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultParsedCacheSize задаёт по умолчанию количество дней в кэше разобранных курсов
+const DefaultParsedCacheSize = 366
+
+// ParsedCache хранит в памяти разобранные курсы за ограниченное количество дат
+// и вытесняет давно не использованные (LRU). Позволяет повторным анализам в одном
+// процессе не загружать и не разбирать ответы заново.
+// Безопасен для одновременного использования из нескольких горутин.
+type ParsedCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List                  // Даты от недавно использованной к давно использованной
+	entries map[time.Time]*list.Element // Элементы order по дате
+}
+
+// parsedEntry содержит разобранные курсы за дату в списке ParsedCache.order
+type parsedEntry struct {
+	date    time.Time
+	valCurs ValCurs
+}
+
+// NewParsedCache создаёт кэш разобранных курсов не более чем на size дат
+func NewParsedCache(size int) *ParsedCache {
+	return &ParsedCache{size: size, order: list.New(), entries: make(map[time.Time]*list.Element)}
+}
+
+// get возвращает копию разобранных курсов за дату. Для nil-кэша всегда возвращается false.
+func (c *ParsedCache) get(d time.Time) (ValCurs, bool) {
+	if c == nil {
+		return ValCurs{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[d]
+	if !ok {
+		return ValCurs{}, false
+	}
+	c.order.MoveToFront(e)
+	valCurs := e.Value.(*parsedEntry).valCurs
+	valCurs.Valutes = slices.Clone(valCurs.Valutes)
+	valCurs.Origin = OriginMemory
+	return valCurs, true
+}
+
+// add сохраняет разобранные курсы за дату, вытесняя самую давно использованную дату при переполнении
+func (c *ParsedCache) add(d time.Time, valCurs ValCurs) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	valCurs.Valutes = slices.Clone(valCurs.Valutes)
+	if e, ok := c.entries[d]; ok {
+		e.Value.(*parsedEntry).valCurs = valCurs
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[d] = c.order.PushFront(&parsedEntry{date: d, valCurs: valCurs})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*parsedEntry).date)
+	}
+}
@@ -0,0 +1,70 @@
+package exchangerates
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetcherParsedCacheRepeatedAnalysis(t *testing.T) {
+	var calls atomic.Int32
+	server := failingServer(t, 0, 0, readTestdata(t, "XML_daily_eng.xml"), &calls)
+	dir := t.TempDir()
+	dates := testDates(testDate(time.March, 4), 5)
+	f := &Fetcher{BaseURL: server.URL + "/?date_req=%s", CacheDir: dir, Parsed: NewParsedCache(len(dates))}
+
+	analyse := func() map[string]int {
+		t.Helper()
+		origins := make(map[string]int)
+		for result := range FetchAll(context.Background(), f, dates, 2, 0) {
+			if result.Err != nil {
+				t.Fatalf("%s: %v", result.Date.Format(isoDateLayout), result.Err)
+			}
+			if len(result.ValCurs.Valutes) == 0 {
+				t.Errorf("%s: нет курсов", result.Date.Format(isoDateLayout))
+			}
+			origins[result.ValCurs.Origin]++
+		}
+		return origins
+	}
+
+	if origins := analyse(); origins[OriginNetwork] != len(dates) {
+		t.Fatalf("первый анализ: %v, want %d из сети", origins, len(dates))
+	}
+	// Без файлового кэша ответы пришлось бы загрузить и разобрать заново
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if origins := analyse(); origins[OriginMemory] != len(dates) {
+		t.Errorf("второй анализ: %v, want %d из памяти", origins, len(dates))
+	}
+	if calls.Load() != int32(len(dates)) {
+		t.Errorf("calls = %d, want %d: повторный анализ не должен обращаться к серверу", calls.Load(), len(dates))
+	}
+}
+
+func TestParsedCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewParsedCache(2)
+	first, second, third := testDate(time.March, 4), testDate(time.March, 5), testDate(time.March, 6)
+	c.add(first, ValCurs{Date: "04.03.2024"})
+	c.add(second, ValCurs{Date: "05.03.2024"})
+	c.get(first) // Вторая дата становится давно использованной
+	c.add(third, ValCurs{Date: "06.03.2024"})
+
+	for _, tt := range []struct {
+		date time.Time
+		ok   bool
+	}{{first, true}, {second, false}, {third, true}} {
+		if valCurs, ok := c.get(tt.date); ok != tt.ok || ok && valCurs.Origin != OriginMemory {
+			t.Errorf("get(%s) = %+v, %v, want %v", tt.date.Format(isoDateLayout), valCurs, ok, tt.ok)
+		}
+	}
+
+	var nilCache *ParsedCache
+	nilCache.add(first, ValCurs{})
+	if _, ok := nilCache.get(first); ok {
+		t.Error("nil-кэш не должен хранить курсы")
+	}
+}
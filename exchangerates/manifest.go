@@ -0,0 +1,33 @@
+package exchangerates
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// manifestEntry описывает один обработанный день в манифесте загрузки
+type manifestEntry struct {
+	Requested string `json:"requested"` // Запрошенная дата (ГГГГ-ММ-ДД) или путь к файлу с ответом
+	Date      string `json:"date"`      // Дата курсов из атрибута Date ответа
+	Source    string `json:"source"`    // Источник курсов: network, cache или input-file
+	Size      int    `json:"size"`      // Размер ответа в байтах
+	Matches   bool   `json:"matches"`   // Совпадает ли дата курсов с запрошенной (см. ValCurs.MatchesRequest)
+}
+
+// writeManifest сохраняет список обработанных дней в JSON-файл, упорядочивая записи по запрошенной дате
+func writeManifest(path string, entries []manifestEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Requested < entries[j].Requested
+	})
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Ошибка при формировании манифеста: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("Ошибка при записи манифеста: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package exchangerates
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readManifest читает записи манифеста из JSON-файла
+func readManifest(t *testing.T, path string) []manifestEntry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []manifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestRunManifest(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	server := cbrServer(t, map[string]string{"04/03/2024": "90", "05/03/2024": "91"}, nil)
+	cacheDir, dir := t.TempDir(), t.TempDir()
+	start, end := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	// Первый запуск загружает курсы из сети, второй — из кэша
+	for _, source := range []string{OriginNetwork, OriginCache} {
+		cfg := NewConfig(&Fetcher{BaseURL: server.URL + "/?date_req=%s", Client: server.Client(), CacheDir: cacheDir}, start, end)
+		cfg.ManifestPath = filepath.Join(dir, source+".json")
+		if _, _, err := Run(context.Background(), cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		entries := readManifest(t, cfg.ManifestPath)
+		if len(entries) != 2 {
+			t.Fatalf("%s: записей = %d, want 2", source, len(entries))
+		}
+		for i, want := range []struct{ requested, date string }{{"2024-03-04", "04.03.2024"}, {"2024-03-05", "05.03.2024"}} {
+			e := entries[i]
+			if e.Requested != want.requested || e.Date != want.date || e.Source != source || e.Size == 0 || !e.Matches {
+				t.Errorf("%s: entry %d = %+v", source, i, e)
+			}
+		}
+	}
+}
+
+func TestRunManifestInputDir(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	dir := writeInputDir(t, map[string]string{"01.02.2024": "89,5"})
+
+	cfg := NewConfig(nil, time.Time{}, time.Time{})
+	cfg.InputDir = dir
+	cfg.MaxFailedRatio = 1
+	cfg.ManifestPath = filepath.Join(t.TempDir(), "manifest.json")
+	if _, _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readManifest(t, cfg.ManifestPath)
+	if len(entries) != 1 || entries[0].Requested != filepath.Join(dir, "01.02.2024.xml") || entries[0].Source != OriginFile {
+		t.Errorf("entries = %+v", entries)
+	}
+}
@@ -0,0 +1,30 @@
+package exchangerates
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Метрики Prometheus, регистрируемые в реестре по умолчанию
+var (
+	fetchAttempts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "exchange_rates_fetch_attempts_total",
+		Help: "Количество запросов к API курсов валют.",
+	})
+	fetchSuccesses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "exchange_rates_fetch_successes_total",
+		Help: "Количество успешных запросов к API курсов валют.",
+	})
+	fetchFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "exchange_rates_fetch_failures_total",
+		Help: "Количество неудачных запросов к API курсов валют.",
+	})
+	parseFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "exchange_rates_parse_failures_total",
+		Help: "Количество ответов API, которые не удалось разобрать.",
+	})
+	rateValue = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "exchange_rates_value",
+		Help: "Значение курса валюты за последнюю обработанную дату.",
+	}, []string{"char_code"})
+)
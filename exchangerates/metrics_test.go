@@ -0,0 +1,85 @@
+package exchangerates
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFetchMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(readTestdata(t, "XML_daily_eng.xml")))
+	}))
+	defer server.Close()
+
+	attempts, successes, failures := testutil.ToFloat64(fetchAttempts), testutil.ToFloat64(fetchSuccesses), testutil.ToFloat64(fetchFailures)
+
+	if _, err := FetchCurrencyRates(context.Background(), server.Client(), server.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FetchCurrencyRates(context.Background(), server.Client(), server.URL+"/fail", nil); err == nil {
+		t.Fatal("ожидалась ошибка")
+	}
+
+	if got := testutil.ToFloat64(fetchAttempts) - attempts; got != 2 {
+		t.Errorf("attempts += %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(fetchSuccesses) - successes; got != 1 {
+		t.Errorf("successes += %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(fetchFailures) - failures; got != 1 {
+		t.Errorf("failures += %v, want 1", got)
+	}
+}
+
+func TestParseFailureMetric(t *testing.T) {
+	before := testutil.ToFloat64(parseFailures)
+	if _, err := ParseXML("<ValCurs"); err == nil {
+		t.Fatal("ожидалась ошибка")
+	}
+	if got := testutil.ToFloat64(parseFailures) - before; got != 1 {
+		t.Errorf("parse failures += %v, want 1", got)
+	}
+}
+
+func TestRateValueMetric(t *testing.T) {
+	store := NewStatsStore()
+	store.SetMetrics(true)
+	store.Update(newDay(testDate(time.March, 2), map[string]string{"USD": "91"}))
+	store.Update(newDay(testDate(time.March, 1), map[string]string{"USD": "90"})) // Более ранняя дата не меняет метрику
+
+	if got := testutil.ToFloat64(rateValue.WithLabelValues("USD")); got != 91 {
+		t.Errorf("rate value = %v, want 91", got)
+	}
+}
+
+func TestRunRateValueMetricPrimaryOnly(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	date := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	run := func(values map[string]string, base string, metrics bool) {
+		t.Helper()
+		cfg := NewConfig(stubSource{values: values}, date, date)
+		cfg.Base, cfg.Metrics = base, metrics
+		if _, _, err := Run(context.Background(), cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run(map[string]string{"CHF": "100", "USD": "90"}, "", true)
+	// Пересчитанные курсы и хранилище без метрики не перезаписывают курсы основного периода
+	run(map[string]string{"CHF": "100", "USD": "90"}, "USD", true)
+	run(map[string]string{"CHF": "120"}, "", false)
+
+	if got := testutil.ToFloat64(rateValue.WithLabelValues("CHF")); got != 100 {
+		t.Errorf("rate value = %v, want 100", got)
+	}
+}
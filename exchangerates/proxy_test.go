@@ -0,0 +1,100 @@
+package exchangerates
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+// proxyServer возвращает HTTP-прокси, который пересылает обычные запросы и туннелирует CONNECT.
+// Количество запросов через прокси записывается в calls.
+func proxyServer(t *testing.T, calls *atomic.Int32) *url.URL {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method == http.MethodConnect {
+			tunnel(t, w, r)
+			return
+		}
+
+		req, err := http.NewRequest(r.Method, r.URL.String(), nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// tunnel соединяет клиента CONNECT-запроса с запрошенным хостом
+func tunnel(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	target, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	client, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		target.Close()
+		t.Errorf("Hijack: %v", err)
+		return
+	}
+	go func() {
+		defer target.Close()
+		io.Copy(target, client)
+	}()
+	go func() {
+		defer client.Close()
+		io.Copy(client, target)
+	}()
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("rates"))
+	})
+	tests := []struct {
+		name   string
+		server *httptest.Server
+	}{
+		{"http", httptest.NewServer(handler)},
+		{"https", httptest.NewTLSServer(handler)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer tt.server.Close()
+			var calls atomic.Int32
+			// Сертификат тестового сервера самоподписанный
+			client := NewHTTPClient(DefaultTimeout, proxyServer(t, &calls), true)
+			defer client.CloseIdleConnections()
+
+			data, err := FetchCurrencyRates(context.Background(), client, tt.server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data != "rates" || calls.Load() != 1 {
+				t.Errorf("data = %q, запросов через прокси = %d, want rates и 1", data, calls.Load())
+			}
+		})
+	}
+}
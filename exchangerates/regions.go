@@ -0,0 +1,134 @@
+package exchangerates
+
+import "sort"
+
+// Регионы, по которым группируются валюты (см. CurrencyRegion)
+const (
+	RegionEurope   = "Europe"
+	RegionAsia     = "Asia"
+	RegionAmericas = "Americas"
+	RegionAfrica   = "Africa"
+	RegionOceania  = "Oceania"
+	RegionOther    = "Other"
+)
+
+// currencyRegions сопоставляет символьные коды валют с регионами
+var currencyRegions = map[string]string{
+	"BGN": RegionEurope,
+	"BYN": RegionEurope,
+	"CHF": RegionEurope,
+	"CZK": RegionEurope,
+	"DKK": RegionEurope,
+	"EUR": RegionEurope,
+	"GBP": RegionEurope,
+	"HUF": RegionEurope,
+	"ISK": RegionEurope,
+	"MDL": RegionEurope,
+	"NOK": RegionEurope,
+	"PLN": RegionEurope,
+	"RON": RegionEurope,
+	"RSD": RegionEurope,
+	"RUB": RegionEurope,
+	"SEK": RegionEurope,
+	"UAH": RegionEurope,
+
+	"AED": RegionAsia,
+	"AMD": RegionAsia,
+	"AZN": RegionAsia,
+	"BDT": RegionAsia,
+	"BHD": RegionAsia,
+	"CNY": RegionAsia,
+	"GEL": RegionAsia,
+	"HKD": RegionAsia,
+	"IDR": RegionAsia,
+	"ILS": RegionAsia,
+	"INR": RegionAsia,
+	"IRR": RegionAsia,
+	"JPY": RegionAsia,
+	"KGS": RegionAsia,
+	"KRW": RegionAsia,
+	"KZT": RegionAsia,
+	"MMK": RegionAsia,
+	"MNT": RegionAsia,
+	"MYR": RegionAsia,
+	"OMR": RegionAsia,
+	"PHP": RegionAsia,
+	"QAR": RegionAsia,
+	"SAR": RegionAsia,
+	"SGD": RegionAsia,
+	"THB": RegionAsia,
+	"TJS": RegionAsia,
+	"TMT": RegionAsia,
+	"TRY": RegionAsia,
+	"UZS": RegionAsia,
+	"VND": RegionAsia,
+
+	"ARS": RegionAmericas,
+	"BOB": RegionAmericas,
+	"BRL": RegionAmericas,
+	"CAD": RegionAmericas,
+	"CLP": RegionAmericas,
+	"COP": RegionAmericas,
+	"CUP": RegionAmericas,
+	"MXN": RegionAmericas,
+	"PEN": RegionAmericas,
+	"USD": RegionAmericas,
+
+	"DZD": RegionAfrica,
+	"EGP": RegionAfrica,
+	"ETB": RegionAfrica,
+	"NGN": RegionAfrica,
+	"ZAR": RegionAfrica,
+
+	"AUD": RegionOceania,
+	"NZD": RegionOceania,
+}
+
+// CurrencyRegion возвращает регион валюты по символьному коду или RegionOther для неизвестных кодов
+func CurrencyRegion(code string) string {
+	if region, ok := currencyRegions[code]; ok {
+		return region
+	}
+	return RegionOther
+}
+
+// RegionStats содержит статистику по валютам одного региона
+type RegionStats struct {
+	Region        string                   // Название региона
+	Stats         map[string]CurrencyStats // Статистика по символьному коду валюты
+	ChangePercent float64                  // Среднее изменение курса валют региона за период в процентах
+}
+
+// GroupByRegion распределяет статистику по валютам по регионам (см. CurrencyRegion)
+// и рассчитывает среднее изменение курса в каждом регионе. Регионы упорядочиваются по названию,
+// RegionOther выводится последним.
+func GroupByRegion(stats map[string]CurrencyStats) []RegionStats {
+	byRegion := make(map[string]map[string]CurrencyStats)
+	for code, s := range stats {
+		region := CurrencyRegion(code)
+		if byRegion[region] == nil {
+			byRegion[region] = make(map[string]CurrencyStats)
+		}
+		byRegion[region][code] = s
+	}
+
+	groups := make([]RegionStats, 0, len(byRegion))
+	for region, regionStats := range byRegion {
+		var total float64
+		for _, s := range regionStats {
+			total += s.ChangePercent()
+		}
+		groups = append(groups, RegionStats{
+			Region:        region,
+			Stats:         regionStats,
+			ChangePercent: total / float64(len(regionStats)),
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if (groups[i].Region == RegionOther) != (groups[j].Region == RegionOther) {
+			return groups[j].Region == RegionOther
+		}
+		return groups[i].Region < groups[j].Region
+	})
+	return groups
+}
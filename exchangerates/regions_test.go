@@ -0,0 +1,59 @@
+package exchangerates
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestCurrencyRegion(t *testing.T) {
+	tests := map[string]string{
+		"USD": RegionAmericas,
+		"EUR": RegionEurope,
+		"CNY": RegionAsia,
+		"ZAR": RegionAfrica,
+		"AUD": RegionOceania,
+		"XDR": RegionOther,
+		"XYZ": RegionOther,
+	}
+	for code, want := range tests {
+		if got := CurrencyRegion(code); got != want {
+			t.Errorf("CurrencyRegion(%s) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestGroupByRegion(t *testing.T) {
+	groups := GroupByRegion(map[string]CurrencyStats{
+		"USD": seriesStats(90, 99),  // +10%
+		"CAD": seriesStats(60, 72),  // +20%
+		"EUR": seriesStats(100, 90), // -10%
+		"JPY": seriesStats(60, 60),
+		"XYZ": seriesStats(10, 11), // Неизвестный код
+	})
+
+	want := []struct {
+		region string
+		codes  []string
+		change float64
+	}{
+		{RegionAmericas, []string{"CAD", "USD"}, 15},
+		{RegionAsia, []string{"JPY"}, 0},
+		{RegionEurope, []string{"EUR"}, -10},
+		{RegionOther, []string{"XYZ"}, 10},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("len(groups) = %d, want %d: %+v", len(groups), len(want), groups)
+	}
+	for i, w := range want {
+		g := groups[i]
+		codes := make([]string, 0, len(g.Stats))
+		for code := range g.Stats {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		if g.Region != w.region || !slices.Equal(codes, w.codes) || math.Abs(g.ChangePercent-w.change) > 1e-9 {
+			t.Errorf("groups[%d] = %s %v %.4f, want %s %v %.4f", i, g.Region, codes, g.ChangePercent, w.region, w.codes, w.change)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package exchangerates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultMaxFailedRatio задаёт допустимую по умолчанию долю дней с ошибками загрузки
+const DefaultMaxFailedRatio = 0.5
+
+// DefaultOutlierPercent задаёт изменение курса за день в процентах, после которого день считается выбросом
+const DefaultOutlierPercent = 50
+
+// Summary подсчитывает результаты загрузки курсов по дням
+type Summary struct {
+	Requested   int // Количество запрошенных дней
+	Succeeded   int // Количество успешно загруженных дней
+	Skipped     int // Количество дней без публикации курсов
+	NoData      int // Количество дней, ответ за которые не содержит ни одной валюты
+	Failed      int // Количество дней с ошибками загрузки или разбора
+	Interrupted int // Количество дней, не обработанных из-за прерывания загрузки
+}
+
+// record учитывает результат загрузки за день и журналирует ошибку.
+// Возвращает true, если курсы за день получены и их нужно обработать.
+func (r *Summary) record(result FetchResult) bool {
+	switch {
+	case result.Err == nil:
+		r.Succeeded++
+		return true
+	case errors.Is(result.Err, context.Canceled):
+		// Прерванные загрузки учитываются после завершения цикла вместе с необработанными днями
+	case errors.Is(result.Err, ErrStaleDate):
+		r.Skipped++
+		slog.Info("Пропуск дня без публикации курсов", "date", result.Date.Format(isoDateLayout), "error", result.Err)
+	case errors.Is(result.Err, ErrNoRates):
+		r.NoData++
+		slog.Info("Пропуск дня без данных", "date", result.Date.Format(isoDateLayout))
+	default:
+		r.Failed++
+		slog.Warn("Не удалось получить курсы", "date", result.Date.Format(isoDateLayout), "error", result.Err)
+	}
+	return false
+}
+
+// FailedRatio возвращает долю запрошенных дней, загрузка которых завершилась ошибкой
+func (r Summary) FailedRatio() float64 {
+	if r.Requested == 0 {
+		return 0
+	}
+	return float64(r.Failed) / float64(r.Requested)
+}
+
+func (r Summary) String() string {
+	s := fmt.Sprintf("Обработано дней: %d/%d, пропущено: %d, без данных: %d, с ошибками: %d",
+		r.Succeeded, r.Requested, r.Skipped, r.NoData, r.Failed)
+	if r.Interrupted > 0 {
+		s += fmt.Sprintf(", прервано: %d", r.Interrupted)
+	}
+	return s
+}
+
+// Config описывает параметры загрузки и анализа курсов валют (см. Run)
+type Config struct {
+	Source         RateSource    // Источник курсов валют
+	Start          time.Time     // Начальная дата периода
+	End            time.Time     // Конечная дата периода включительно
+	Weekends       bool          // Запрашивать курсы и за выходные дни
+	Dates          []time.Time   // Запрашиваемые даты вместо всех дат периода; Start и End задают их границы
+	Concurrency    int           // Количество параллельных запросов к источнику
+	DayTimeout     time.Duration // Ограничение времени загрузки курсов за один день; 0 отключает ограничение
+	InputDir       string        // Каталог с сохранёнными XML-ответами; если задан, курсы не загружаются
+	DBPath         string        // Путь к базе данных SQLite; пустая строка отключает базу данных
+	Base           string        // Базовая валюта, относительно которой пересчитываются курсы; пустая строка означает валюту источника
+	Currencies     []string      // Учитываемые символьные коды валют; пустой список означает все валюты
+	Exact          bool          // Точный расчёт среднего значения курса (см. StatsStore.SetExact)
+	MaxFailedRatio float64       // Допустимая доля дней с ошибками загрузки
+	Stats          *StatsStore   // Хранилище, в котором накапливается статистика
+	OutlierPercent float64       // Изменение курса за день в процентах, после которого день считается выбросом
+	DropOutliers   bool          // Исключать выбросы из статистики
+	ManifestPath   string        // Путь к JSON-файлу со списком обработанных дней; пустая строка отключает запись
+	Stream         io.Writer     // Получатель курсов каждого обработанного дня в формате JSON Lines; nil отключает вывод
+	FailFast       bool          // Прерывать работу при первой ошибке загрузки или разбора
+	BatchSize      int           // Количество дат в пакете загрузки; 0 загружает все даты одним пакетом
+	BatchPause     time.Duration // Пауза между пакетами загрузки
+	Metrics        bool          // Публиковать курсы в метрике exchange_rates_value, если они не пересчитываются к другой базовой валюте
+
+	// OnDay вызывается для каждого успешно разобранного дня до учёта курсов в статистике.
+	// Первый аргумент — запрошенная дата (ГГГГ-ММ-ДД) или путь к файлу при InputDir.
+	// Вызовы выполняются последовательно из горутины Run, поэтому синхронизация не требуется;
+	// долгая обработка задерживает учёт следующих дней.
+	OnDay func(requested string, valCurs ValCurs)
+}
+
+// NewConfig создаёт конфигурацию загрузки курсов из source за период от start до end
+// со значениями остальных параметров по умолчанию и собственным хранилищем статистики.
+// Конфигурации с разными хранилищами можно использовать в Run одновременно.
+func NewConfig(source RateSource, start, end time.Time) Config {
+	return Config{
+		Source:         source,
+		Start:          start,
+		End:            end,
+		Concurrency:    DefaultConcurrency,
+		DayTimeout:     DefaultDayTimeout,
+		MaxFailedRatio: DefaultMaxFailedRatio,
+		Stats:          NewStatsStore(),
+		OutlierPercent: DefaultOutlierPercent,
+	}
+}
+
+// RequestDates возвращает даты периода, за которые запрашиваются курсы
+func (c Config) RequestDates() []time.Time {
+	if len(c.Dates) > 0 {
+		return c.Dates
+	}
+	dates := datesInRange(c.Start, c.End)
+	if !c.Weekends {
+		dates = skipWeekends(dates)
+	}
+	return dates
+}
+
+// datesInRange возвращает список дат от start до end включительно
+func datesInRange(start, end time.Time) []time.Time {
+	var dates []time.Time
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// skipWeekends возвращает даты без суббот и воскресений, в которые ЦБ РФ не устанавливает курсы
+func skipWeekends(dates []time.Time) []time.Time {
+	var workdays []time.Time
+	for _, d := range dates {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		workdays = append(workdays, d)
+	}
+	return workdays
+}
+
+// ErrTooManyFailed возвращается Run, если доля дней с ошибками загрузки превышает допустимую
+var ErrTooManyFailed = errors.New("доля дней с ошибками загрузки превышает допустимую")
+
+// ErrNoData возвращается Run, если за период не получено ни одного курса валюты
+var ErrNoData = errors.New("не получено ни одного курса валюты: проверьте доступность источника и период")
+
+// Run загружает курсы валют за период, анализирует их и возвращает статистику по валютам
+// и сводку результатов загрузки по дням. Run ничего не выводит, кроме журнала slog и cfg.Stream,
+// поэтому может использоваться из других программ.
+// Если данных не получено, возвращается сводка и ошибка ErrNoData. При превышении допустимой
+// доли дней с ошибками статистика и сводка возвращаются вместе с ошибкой ErrTooManyFailed.
+func Run(ctx context.Context, cfg Config) (map[string]CurrencyStats, Summary, error) {
+	cfg.Stats.SetFilter(cfg.Currencies)
+	cfg.Stats.SetExact(cfg.Exact)
+
+	var rateDB *RateDB
+	if cfg.DBPath != "" {
+		var err error
+		rateDB, err = OpenRateDB(cfg.DBPath)
+		if err != nil {
+			return nil, Summary{}, err
+		}
+		defer rateDB.Close()
+	}
+	// Сохранённые XML-ответы имеют формат ЦБ РФ и выражены в рублях
+	dbBase := BaseRUB
+	if cfg.InputDir == "" {
+		dbBase = cfg.Source.BaseCurrency()
+	}
+	cfg.Stats.SetMetrics(cfg.Metrics && (cfg.Base == "" || strings.EqualFold(cfg.Base, dbBase)))
+
+	var manifest []manifestEntry
+	var stored []time.Time // Запрошенные даты, статистика за которые рассчитывается по базе данных
+	process := func(requested string, valCurs ValCurs) {
+		if cfg.OnDay != nil {
+			cfg.OnDay(requested, valCurs)
+		}
+
+		manifest = append(manifest, manifestEntry{
+			Requested: requested,
+			Date:      valCurs.Date,
+			Source:    valCurs.Origin,
+			Size:      valCurs.Size,
+			Matches:   valCurs.MatchesRequest(),
+		})
+
+		if cfg.Stream != nil {
+			if err := writeDay(cfg.Stream, valCurs, cfg.Currencies); err != nil {
+				slog.Warn("Не удалось вывести курсы за день", "date", valCurs.Date, "error", err)
+			}
+		}
+
+		if rateDB != nil {
+			// Статистика рассчитывается по базе данных после загрузки всех дней
+			if err := rateDB.Save(valCurs); err != nil {
+				slog.Warn("Не удалось сохранить курсы в базу данных", "date", valCurs.Date, "error", err)
+				return
+			}
+			if cfg.InputDir != "" {
+				// Курсы из файла сохраняются на дату самих курсов (см. RateDB.Save)
+				stored = append(stored, valCurs.Time)
+			}
+			return
+		}
+		updateStats(cfg.Stats, valCurs, cfg.Base) // Анализ данных и обновление статистики
+	}
+
+	var summary Summary
+	if cfg.InputDir != "" {
+		var err error
+		summary, err = readInputDir(cfg.InputDir, cfg.FailFast, process)
+		if err != nil {
+			return nil, Summary{}, err
+		}
+	} else {
+		dates := cfg.RequestDates()
+		if rateDB != nil {
+			// Загружаются только даты, курсов за которые ещё нет в базе данных;
+			// статистика затем рассчитывается по всем сохранённым дням периода
+			missing, err := rateDB.MissingDates(dbBase, dates)
+			if err != nil {
+				return nil, Summary{}, err
+			}
+			slog.Info("Пропуск дат, сохранённых в базе данных", "stored", len(dates)-len(missing), "missing", len(missing))
+			stored = dates
+			dates = missing
+		}
+		summary.Requested = len(dates)
+
+		// При cfg.FailFast первая ошибка отменяет оставшиеся загрузки;
+		// результаты продолжают вычитываться, чтобы завершились все горутины
+		fetchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var firstErr error
+		for result := range FetchBatches(fetchCtx, cfg.Source, dates, cfg.Concurrency, cfg.DayTimeout, cfg.BatchSize, cfg.BatchPause) {
+			if firstErr != nil {
+				continue
+			}
+			if cfg.FailFast && result.Err != nil && !errors.Is(result.Err, context.Canceled) &&
+				!errors.Is(result.Err, ErrStaleDate) && !errors.Is(result.Err, ErrNoRates) {
+				firstErr = result.Err
+				cancel()
+				continue
+			}
+			if summary.record(result) {
+				process(result.Date.Format(isoDateLayout), result.ValCurs)
+			}
+		}
+		if firstErr != nil {
+			return nil, Summary{}, firstErr
+		}
+		if ctx.Err() != nil {
+			summary.Interrupted = summary.Requested - summary.Succeeded - summary.Skipped - summary.NoData - summary.Failed
+			slog.Warn("Получен сигнал прерывания, загрузка остановлена, статистика рассчитывается по обработанным дням")
+		}
+	}
+
+	if rateDB != nil {
+		days, err := rateDB.Load(dbBase, stored)
+		if err != nil {
+			return nil, Summary{}, err
+		}
+		for _, valCurs := range days {
+			updateStats(cfg.Stats, valCurs, cfg.Base)
+		}
+	}
+
+	if cfg.ManifestPath != "" {
+		if err := writeManifest(cfg.ManifestPath, manifest); err != nil {
+			return nil, summary, err
+		}
+	}
+
+	reportOutliers(cfg)
+
+	snapshot := cfg.Stats.Snapshot()
+	for _, code := range cfg.Currencies {
+		if _, ok := snapshot[code]; !ok {
+			slog.Warn("Валюта не найдена в данных", "code", code)
+		}
+	}
+
+	if len(snapshot) == 0 {
+		return nil, summary, fmt.Errorf("%w (%s)", ErrNoData, summary)
+	}
+	if summary.FailedRatio() > cfg.MaxFailedRatio {
+		return snapshot, summary, fmt.Errorf("%w: %d из %d, допустимо %.2f",
+			ErrTooManyFailed, summary.Failed, summary.Requested, cfg.MaxFailedRatio)
+	}
+	return snapshot, summary, nil
+}
+
+// readInputDir разбирает сохранённые ответы ЦБ РФ из всех XML-файлов каталога dir
+// и передаёт путь к файлу и курсы каждого дня в process. Файлы с ошибками разбора пропускаются.
+func readInputDir(dir string, failFast bool, process func(string, ValCurs)) (Summary, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return Summary{}, fmt.Errorf("Ошибка при поиске XML-файлов: %w", err)
+	}
+
+	summary := Summary{Requested: len(paths)}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if failFast {
+				return summary, fmt.Errorf("Ошибка при чтении файла %s: %w", path, err)
+			}
+			summary.Failed++
+			slog.Warn("Не удалось прочитать файл", "path", path, "error", err)
+			continue
+		}
+
+		valCurs, err := ParseXML(string(data))
+		if err != nil {
+			if failFast {
+				return summary, fmt.Errorf("Ошибка при разборе файла %s: %w", path, err)
+			}
+			summary.Failed++
+			slog.Warn("Не удалось разобрать файл", "path", path, "error", err)
+			continue
+		}
+		if err := valCurs.Validate(); err != nil {
+			slog.Warn("Файл не прошёл проверку", "path", path, "error", err)
+		}
+		if len(valCurs.Valutes) == 0 {
+			summary.NoData++
+			slog.Info("Пропуск файла без данных", "path", path)
+			continue
+		}
+		summary.Succeeded++
+		valCurs.Origin, valCurs.Size = OriginFile, len(data)
+		process(path, valCurs)
+	}
+	return summary, nil
+}
+
+// updateStats пересчитывает курсы за день к базовой валюте base и учитывает их в store
+func updateStats(store *StatsStore, valCurs ValCurs, base string) {
+	rebased, err := Rebase(valCurs, base)
+	if err != nil {
+		slog.Warn("Пропуск дня без курса базовой валюты", "date", valCurs.Date, "error", err)
+		return
+	}
+	store.Update(rebased)
+}
+
+// reportOutliers журналирует резкие изменения курсов за день и при cfg.DropOutliers
+// исключает их из статистики
+func reportOutliers(cfg Config) {
+	if cfg.OutlierPercent <= 0 {
+		return
+	}
+
+	outliers := make(map[string][]RatePoint)
+	if cfg.DropOutliers {
+		outliers = cfg.Stats.DropOutliers(cfg.OutlierPercent)
+	} else {
+		for code, stats := range cfg.Stats.Snapshot() {
+			outliers[code] = stats.Outliers(cfg.OutlierPercent)
+		}
+	}
+
+	for code, points := range outliers {
+		for _, p := range points {
+			slog.Warn("Подозрительное изменение курса за день", "code", code,
+				"date", p.Date.Format(isoDateLayout), "value", p.Value, "dropped", cfg.DropOutliers)
+		}
+	}
+}
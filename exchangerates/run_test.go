@@ -0,0 +1,579 @@
+package exchangerates
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSkipWeekends(t *testing.T) {
+	// Период с четверга 2024-03-07 по вторник 2024-03-12 включает выходные 9 и 10 марта
+	start := time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+
+	var got []string
+	for _, d := range skipWeekends(datesInRange(start, end)) {
+		got = append(got, d.Format(isoDateLayout))
+	}
+	want := "2024-03-07,2024-03-08,2024-03-11,2024-03-12"
+	if strings.Join(got, ",") != want {
+		t.Errorf("skipWeekends = %v, want %s", got, want)
+	}
+}
+
+// captureLog перенаправляет журнал в буфер в формате JSON до завершения теста
+func captureLog(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestFailedFetchLogsWarn(t *testing.T) {
+	buf := captureLog(t, slog.LevelWarn)
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	var summary Summary
+	summary.record(FetchResult{Date: date, Err: fmt.Errorf("Ошибка при запросе к API: %w", errors.New("connection refused"))})
+	// Дни без публикации курсов журналируются на уровне info и не попадают в журнал warn
+	summary.record(FetchResult{Date: date.AddDate(0, 0, 1), Err: ErrStaleDate})
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("некорректная запись журнала %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("записей журнала: %d, want 1:\n%s", len(entries), buf)
+	}
+	if entries[0]["level"] != "WARN" || entries[0]["date"] != "2024-03-01" || !strings.Contains(entries[0]["error"].(string), "connection refused") {
+		t.Errorf("entry = %v", entries[0])
+	}
+	if summary.Failed != 1 || summary.Skipped != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+// stubSource возвращает одинаковые курсы values за любую дату, кроме дат failed (ГГГГ-ММ-ДД),
+// загрузка которых завершается ошибкой
+type stubSource struct {
+	values map[string]string
+	failed map[string]bool
+}
+
+func (s stubSource) BaseCurrency() string {
+	return BaseRUB
+}
+
+func (s stubSource) FetchRates(ctx context.Context, date time.Time) (ValCurs, error) {
+	if s.failed[date.Format(isoDateLayout)] {
+		return ValCurs{}, &StatusError{StatusCode: http.StatusInternalServerError}
+	}
+	valCurs := ValCurs{Date: date.Format("02.01.2006"), Time: date, Request: date}
+	for code, value := range s.values {
+		valCurs.Valutes = append(valCurs.Valutes, Valute{ID: code, NumCode: code, CharCode: code, Nominal: 1, Name: code, Value: value})
+	}
+	return valCurs, nil
+}
+
+func TestRunPartialFailures(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	// Период с понедельника 2024-03-04 по пятницу 2024-03-08: два из пяти дней с ошибками
+	start, end := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	source := stubSource{values: map[string]string{"USD": "90"}, failed: map[string]bool{"2024-03-05": true, "2024-03-07": true}}
+
+	dates := datesInRange(start, end)
+	summary := Summary{Requested: len(dates)}
+	for result := range FetchAll(context.Background(), source, dates, 2, 0) {
+		summary.record(result)
+	}
+	if summary.Succeeded != 3 || summary.Failed != 2 {
+		t.Errorf("summary = %+v, want 3 успешных и 2 с ошибками", summary)
+	}
+	if got := summary.String(); !strings.Contains(got, "3/5") || !strings.Contains(got, "с ошибками: 2") {
+		t.Errorf("summary = %q", got)
+	}
+	if got := summary.FailedRatio(); got != 0.4 {
+		t.Errorf("FailedRatio() = %v, want 0.4", got)
+	}
+
+	cfg := NewConfig(source, start, end)
+	cfg.MaxFailedRatio = 0.5
+	stats, runSummary, err := Run(context.Background(), cfg)
+	if err != nil || stats["USD"].Count != 3 {
+		t.Errorf("Run(MaxFailedRatio = 0.5): Count = %d, err = %v, want 3 и nil", stats["USD"].Count, err)
+	}
+	// Сводка возвращается вызывающей программе, а не выводится
+	if runSummary != summary {
+		t.Errorf("Run(MaxFailedRatio = 0.5): summary = %+v, want %+v", runSummary, summary)
+	}
+
+	cfg = NewConfig(source, start, end)
+	cfg.MaxFailedRatio = 0.3
+	stats, _, err = Run(context.Background(), cfg)
+	if !errors.Is(err, ErrTooManyFailed) || stats["USD"].Count != 3 {
+		t.Errorf("Run(MaxFailedRatio = 0.3): Count = %d, err = %v, want 3 и ErrTooManyFailed", stats["USD"].Count, err)
+	}
+}
+
+// writeInputDir сохраняет в каталог ответы ЦБ РФ за даты (ДД.ММ.ГГГГ) с курсами USD usd
+// и некорректный XML-файл и возвращает путь к каталогу
+func writeInputDir(t *testing.T, usd map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for date, value := range usd {
+		data := `<?xml version="1.0" encoding="windows-1251"?><ValCurs Date="` + date + `" name="Foreign Currency Market">` +
+			`<Valute ID="R01235"><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>US Dollar</Name><Value>` + value + `</Value></Valute>` +
+			`<Valute ID="R01820"><NumCode>392</NumCode><CharCode>JPY</CharCode><Nominal>100</Nominal><Name>Japanese Yen</Name><Value>60,5</Value></Valute></ValCurs>`
+		if err := os.WriteFile(filepath.Join(dir, date+".xml"), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, data := range map[string]string{"broken.xml": "<ValCurs Date=", "notes.txt": "не XML"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunInputDir(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	dir := writeInputDir(t, map[string]string{"01.02.2024": "89,5", "02.02.2024": "90,5"})
+
+	for _, withDB := range []bool{false, true} {
+		cfg := NewConfig(nil, time.Time{}, time.Time{})
+		cfg.InputDir = dir
+		cfg.MaxFailedRatio = 1
+		if withDB {
+			cfg.DBPath = filepath.Join(t.TempDir(), "rates.db")
+		}
+
+		stats, _, err := Run(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("Run(db = %v): %v", withDB, err)
+		}
+		usd, jpy := stats["USD"], stats["JPY"]
+		if len(stats) != 2 || usd.Count != 2 || usd.Average != 90 || usd.MinDate != "01.02.2024" || usd.MaxDate != "02.02.2024" {
+			t.Errorf("Run(db = %v): USD = %+v", withDB, usd)
+		}
+		if jpy.Nominal != 100 || jpy.Average != 60.5 {
+			t.Errorf("Run(db = %v): JPY = %+v", withDB, jpy)
+		}
+	}
+}
+
+// cbrServer возвращает сервер, который отвечает на запрос XML_daily курсами USD из usd
+// по дате запроса (ДД/ММ/ГГГГ) и записывает запрошенные даты в requested
+func cbrServer(t *testing.T, usd map[string]string, requested *sync.Map) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dateReq := r.URL.Query().Get("date_req")
+		if requested != nil {
+			requested.Store(dateReq, true)
+		}
+		value, ok := usd[dateReq]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		date := strings.ReplaceAll(dateReq, "/", ".")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="windows-1251"?><ValCurs Date="%s" name="Foreign Currency Market">`+
+			`<Valute ID="R01235"><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>US Dollar</Name><Value>%s</Value></Valute></ValCurs>`,
+			date, value)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunHTTPSource(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	server := cbrServer(t, map[string]string{"04/03/2024": "90", "05/03/2024": "91,5", "06/03/2024": "92,5"}, nil)
+
+	source := &Fetcher{BaseURL: server.URL + "/scripts/XML_daily_eng.asp?date_req=%s", Client: server.Client()}
+	stats, _, err := Run(context.Background(), NewConfig(source, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usd := stats["USD"]
+	if len(stats) != 1 || usd.Count != 3 || usd.Average != 91.33333333333333 || usd.MaxValue != 92.5 || usd.MaxDate != "06.03.2024" || usd.MinDate != "04.03.2024" {
+		t.Errorf("USD = %+v", usd)
+	}
+}
+
+func TestRunFetchesOnlyMissingDates(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	usd := map[string]string{"04/03/2024": "90", "05/03/2024": "91", "06/03/2024": "92", "07/03/2024": "93", "08/03/2024": "94"}
+	var requested sync.Map
+	server := cbrServer(t, usd, &requested)
+	source := &Fetcher{BaseURL: server.URL + "/?date_req=%s", Client: server.Client()}
+
+	// В базе данных уже есть курсы за 4 и 6 марта
+	dbPath := filepath.Join(t.TempDir(), "rates.db")
+	db, err := OpenRateDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range []int{4, 6} {
+		valCurs, err := source.FetchRates(context.Background(), time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Save(valCurs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+	requested.Clear()
+
+	cfg := NewConfig(source, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC))
+	cfg.DBPath = dbPath
+	stats, _, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	requested.Range(func(key, _ any) bool {
+		got = append(got, key.(string))
+		return true
+	})
+	sort.Strings(got)
+	if strings.Join(got, ",") != "05/03/2024,07/03/2024,08/03/2024" {
+		t.Errorf("запрошены даты %v, want только 05, 07 и 08 марта", got)
+	}
+	// Статистика рассчитывается по всем сохранённым дням периода
+	if s := stats["USD"]; s.Count != 5 || s.Average != 92 {
+		t.Errorf("USD Count = %d, Average = %v, want 5 и 92", s.Count, s.Average)
+	}
+}
+
+func TestRunNoData(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	start, end := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)
+	failed := map[string]bool{"2024-03-04": true, "2024-03-05": true, "2024-03-06": true}
+
+	cfg := NewConfig(stubSource{values: map[string]string{"USD": "90"}, failed: failed}, start, end)
+	cfg.MaxFailedRatio = 1 // Ошибка возвращается и без превышения доли дней с ошибками
+	stats, _, err := Run(context.Background(), cfg)
+	if !errors.Is(err, ErrNoData) {
+		t.Errorf("err = %v, want ErrNoData", err)
+	}
+	if stats != nil {
+		t.Errorf("stats = %v, want nil", stats)
+	}
+}
+
+func TestReportOutliers(t *testing.T) {
+	for _, drop := range []bool{false, true} {
+		buf := captureLog(t, slog.LevelWarn)
+		cfg := NewConfig(nil, time.Time{}, time.Time{})
+		cfg.DropOutliers = drop
+		// Однодневный скачок курса в 10 раз 3 марта
+		cfg.Stats = NewStatsStore()
+		for i, value := range []string{"90", "91", "910", "92", "93"} {
+			cfg.Stats.Update(newDay(time.Date(2024, 3, 1+i, 0, 0, 0, 0, time.UTC), map[string]string{"USD": value}))
+		}
+
+		reportOutliers(cfg)
+
+		if !strings.Contains(buf.String(), "Подозрительное изменение курса") || !strings.Contains(buf.String(), "2024-03-03") ||
+			strings.Count(buf.String(), "\n") != 1 {
+			t.Errorf("drop = %v: журнал = %q, want одно предупреждение за 2024-03-03", drop, buf.String())
+		}
+		usd := cfg.Stats.Snapshot()["USD"]
+		if drop && (usd.Count != 4 || usd.MaxValue != 93 || usd.Average != 91.5) {
+			t.Errorf("drop = true: USD = %+v, want выброс исключён", usd)
+		}
+		if !drop && (usd.Count != 5 || usd.MaxValue != 910) {
+			t.Errorf("drop = false: USD = %+v, want выброс сохранён", usd)
+		}
+	}
+}
+
+func TestSummaryRecordLogLevels(t *testing.T) {
+	// Ошибки отдельных дней журналируются не выше уровня warn, поэтому журнал уровня error
+	// (тихий режим программы) их не содержит
+	for _, tt := range []struct {
+		level slog.Level
+		lines int
+	}{
+		{slog.LevelInfo, 3},
+		{slog.LevelError, 0},
+	} {
+		buf := captureLog(t, tt.level)
+
+		summary := Summary{Requested: 4}
+		date := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+		summary.record(FetchResult{Date: date, Err: errors.New("connection refused")})
+		summary.record(FetchResult{Date: date.AddDate(0, 0, 1), Err: ErrStaleDate})
+		summary.record(FetchResult{Date: date.AddDate(0, 0, 2), Err: ErrNoRates})
+		summary.record(FetchResult{Date: date.AddDate(0, 0, 3)})
+
+		if got := strings.Count(buf.String(), "\n"); got != tt.lines {
+			t.Errorf("%v: строк журнала = %d, want %d:\n%s", tt.level, got, tt.lines, buf)
+		}
+		// Ошибки учитываются в итоговой сводке независимо от уровня журнала
+		if summary.Failed != 1 || summary.Skipped != 1 || summary.NoData != 1 || summary.Succeeded != 1 {
+			t.Errorf("%v: summary = %+v", tt.level, summary)
+		}
+	}
+}
+
+// blockingSource возвращает курсы за даты раньше cutoff, а загрузка остальных дат
+// ожидает отмены контекста, как долгий запрос, прерванный по SIGINT
+type blockingSource struct {
+	stubSource
+	cutoff time.Time
+}
+
+func (s blockingSource) FetchRates(ctx context.Context, date time.Time) (ValCurs, error) {
+	if date.Before(s.cutoff) {
+		return s.stubSource.FetchRates(ctx, date)
+	}
+	<-ctx.Done()
+	return ValCurs{}, ctx.Err()
+}
+
+func TestRunInterruptedReturnsPartialStats(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start, end := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	cfg := NewConfig(blockingSource{stubSource{values: map[string]string{"USD": "90"}}, time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)}, start, end)
+	cfg.Concurrency = 2
+	var processed int
+	cfg.OnDay = func(string, ValCurs) {
+		// Прерывание после обработки всех доступных дней
+		if processed++; processed == 2 {
+			cancel()
+		}
+	}
+
+	done := make(chan struct{})
+	var stats map[string]CurrencyStats
+	var err error
+	go func() {
+		defer close(done)
+		stats, _, err = Run(ctx, cfg)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run не завершился после отмены")
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usd := stats["USD"]; usd.Count != 2 || usd.MaxDate != "05.03.2024" {
+		t.Errorf("USD = %+v, want статистика за 4 и 5 марта", usd)
+	}
+}
+
+// countingSource считает обращения к источнику курсов
+type countingSource struct {
+	stubSource
+	calls atomic.Int32
+}
+
+func (s *countingSource) FetchRates(ctx context.Context, date time.Time) (ValCurs, error) {
+	s.calls.Add(1)
+	return s.stubSource.FetchRates(ctx, date)
+}
+
+func TestRunFailFast(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	// Десять рабочих дней с 2024-03-04 по 2024-03-15, загрузка второго дня завершается ошибкой
+	start, end := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	stub := stubSource{values: map[string]string{"USD": "90"}, failed: map[string]bool{"2024-03-05": true}}
+
+	// По умолчанию ошибка пропускается и загрузка продолжается
+	source := &countingSource{stubSource: stub}
+	stats, _, err := Run(context.Background(), NewConfig(source, start, end))
+	if err != nil || stats["USD"].Count != 9 || source.calls.Load() != 10 {
+		t.Errorf("Run: Count = %d, calls = %d, err = %v, want 9, 10 и nil", stats["USD"].Count, source.calls.Load(), err)
+	}
+
+	source = &countingSource{stubSource: stub}
+	cfg := NewConfig(source, start, end)
+	cfg.FailFast = true
+	cfg.Concurrency = 1
+	_, _, err = Run(context.Background(), cfg)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Run(FailFast): err = %v, want StatusError 500", err)
+	}
+	if calls := source.calls.Load(); calls >= 10 {
+		t.Errorf("Run(FailFast): calls = %d, want загрузка остановлена после ошибки", calls)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	// Рабочие дни с 2024-03-04 по 2024-03-06
+	start, end := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)
+	var stream bytes.Buffer
+	cfg := NewConfig(stubSource{values: map[string]string{"USD": "90,5", "EUR": "98"}}, start, end)
+	cfg.Stream = &stream
+	cfg.Currencies = []string{"USD"}
+	processed := 0
+	cfg.OnDay = func(string, ValCurs) {
+		// Строка дня выводится сразу после его разбора, а не по окончании загрузки
+		if lines := strings.Count(stream.String(), "\n"); lines != processed {
+			t.Errorf("до вывода дня %d выведено строк: %d, want %d", processed+1, lines, processed)
+		}
+		processed++
+	}
+	if _, _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stream.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("строк = %d, want 3:\n%s", len(lines), stream.String())
+	}
+	var dates []string
+	for _, line := range lines {
+		var day struct {
+			Date    string `json:"date"`
+			Valutes []struct {
+				Code  string  `json:"code"`
+				Value float64 `json:"value"`
+			} `json:"valutes"`
+		}
+		if err := json.Unmarshal([]byte(line), &day); err != nil {
+			t.Fatalf("строка %q: %v", line, err)
+		}
+		if len(day.Valutes) != 1 || day.Valutes[0].Code != "USD" || day.Valutes[0].Value != 90.5 {
+			t.Errorf("%s: valutes = %+v, want только USD 90.5", day.Date, day.Valutes)
+		}
+		dates = append(dates, day.Date)
+	}
+	sort.Strings(dates)
+	if want := "2024-03-04,2024-03-05,2024-03-06"; strings.Join(dates, ",") != want {
+		t.Errorf("dates = %v, want %s", dates, want)
+	}
+}
+
+func TestRunSummaryNoData(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	// За 05.03.2024 ответ содержит только пустой элемент ValCurs
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		date := strings.ReplaceAll(r.URL.Query().Get("date_req"), "/", ".")
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="windows-1251"?><ValCurs Date="%s" name="Foreign Currency Market">`, date)
+		if date != "05.03.2024" {
+			fmt.Fprint(w, `<Valute ID="R01235"><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>US Dollar</Name><Value>90</Value></Valute>`)
+		}
+		fmt.Fprint(w, `</ValCurs>`)
+	}))
+	defer server.Close()
+
+	source := &Fetcher{BaseURL: server.URL + "/?date_req=%s", Client: server.Client()}
+	dates := datesInRange(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC))
+	summary := Summary{Requested: len(dates)}
+	for result := range FetchAll(context.Background(), source, dates, 2, 0) {
+		if result.Date.Format(isoDateLayout) == "2024-03-05" && !errors.Is(result.Err, ErrNoRates) {
+			t.Errorf("2024-03-05: err = %v, want ErrNoRates", result.Err)
+		}
+		summary.record(result)
+	}
+
+	if summary.Succeeded != 2 || summary.NoData != 1 || summary.Failed != 0 {
+		t.Errorf("summary = %+v, want 2 успешных и 1 без данных", summary)
+	}
+	if got := summary.String(); !strings.Contains(got, "без данных: 1") || !strings.Contains(got, "с ошибками: 0") {
+		t.Errorf("summary = %q", got)
+	}
+}
+
+func TestRunOnDay(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	// Рабочие дни с 2024-03-04 по 2024-03-08, загрузка за 2024-03-06 завершается ошибкой
+	start, end := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	cfg := NewConfig(stubSource{values: map[string]string{"USD": "90"}, failed: map[string]bool{"2024-03-06": true}}, start, end)
+
+	calls := make(map[string]int)
+	cfg.OnDay = func(requested string, valCurs ValCurs) {
+		// Хук вызывается последовательно, поэтому обращение к calls не требует синхронизации
+		calls[requested]++
+		if want := valCurs.Time.Format(isoDateLayout); requested != want {
+			t.Errorf("OnDay(%s): курсы за %s", requested, want)
+		}
+		// Курсы дня ещё не учтены в статистике
+		if count := cfg.Stats.Snapshot()["USD"].Count; count != len(calls)-1 {
+			t.Errorf("OnDay(%s): Count = %d, want %d", requested, count, len(calls)-1)
+		}
+	}
+	if _, _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 4 || calls["2024-03-06"] != 0 {
+		t.Errorf("calls = %v, want четыре успешных дня без 2024-03-06", calls)
+	}
+	for date, n := range calls {
+		if n != 1 {
+			t.Errorf("OnDay(%s) вызван %d раз, want 1", date, n)
+		}
+	}
+}
+
+func TestRunConcurrentConfigs(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	source := stubSource{values: map[string]string{"USD": "90", "EUR": "98", "JPY": "60"}}
+	// Рабочие дни с 2024-03-04 по 2024-03-08 и с 2024-03-11 по 2024-03-13
+	usdCfg := NewConfig(source, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC))
+	usdCfg.Currencies = []string{"USD"}
+	otherCfg := NewConfig(source, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC))
+	otherCfg.Currencies = []string{"EUR", "JPY"}
+	otherCfg.Exact = true
+
+	results := make([]map[string]CurrencyStats, 2)
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, cfg := range []Config{usdCfg, otherCfg} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _, errs[i] = Run(context.Background(), cfg)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Run %d: %v", i+1, err)
+		}
+	}
+	if usd := results[0]; len(usd) != 1 || usd["USD"].Count != 5 || usd["USD"].MinDate != "04.03.2024" {
+		t.Errorf("Run 1 = %v, want только USD за 5 дней", SortedStats(usd))
+	}
+	other := results[1]
+	if len(other) != 2 || other["EUR"].Count != 3 || other["JPY"].Count != 3 || other["EUR"].MinDate != "11.03.2024" {
+		t.Errorf("Run 2 = %v, want только EUR и JPY за 3 дня", SortedStats(other))
+	}
+	// Статистика накапливается в хранилище своей конфигурации
+	if len(usdCfg.Stats.Snapshot()) != 1 || len(otherCfg.Stats.Snapshot()) != 2 {
+		t.Errorf("хранилища: %d и %d валют, want 1 и 2", len(usdCfg.Stats.Snapshot()), len(otherCfg.Stats.Snapshot()))
+	}
+}
@@ -0,0 +1,114 @@
+package exchangerates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateSource описывает источник ежедневных курсов валют.
+// Курсы приводятся к общей структуре ValCurs независимо от формата источника.
+type RateSource interface {
+	// FetchRates возвращает курсы валют за указанную дату
+	FetchRates(ctx context.Context, date time.Time) (ValCurs, error)
+	// BaseCurrency возвращает символьный код валюты, в которой источник публикует курсы
+	BaseCurrency() string
+}
+
+// FetchResult содержит результат загрузки курсов валют за одну дату
+type FetchResult struct {
+	Date    time.Time // Запрошенная дата
+	ValCurs ValCurs   // Разобранные курсы валют, если загрузка успешна
+	Err     error     // Ошибка загрузки или разбора
+}
+
+// FetchAll загружает курсы валют из source за все даты в concurrency параллельных горутинах.
+// Загрузка за одну дату, включая повторные попытки, ограничена timeout, чтобы медленный
+// ответ не занимал горутину дольше; при timeout <= 0 ограничение не применяется.
+// Результаты, в том числе неудачные, отправляются в возвращаемый канал,
+// который закрывается после обработки всех дат.
+func FetchAll(ctx context.Context, source RateSource, dates []time.Time, concurrency int, timeout time.Duration) <-chan FetchResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan time.Time)
+	results := make(chan FetchResult)
+
+	go func() {
+		defer close(jobs)
+		for _, d := range dates {
+			select {
+			case jobs <- d:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range jobs {
+				valCurs, err := fetchDay(ctx, source, d, timeout)
+				results <- FetchResult{Date: d, ValCurs: valCurs, Err: err}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// fetchDay загружает курсы валют за дату d с ограничением времени timeout
+func fetchDay(ctx context.Context, source RateSource, d time.Time, timeout time.Duration) (ValCurs, error) {
+	if timeout <= 0 {
+		return source.FetchRates(ctx, d)
+	}
+
+	dayCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	valCurs, err := source.FetchRates(dayCtx, d)
+	if err != nil && ctx.Err() == nil && errors.Is(dayCtx.Err(), context.DeadlineExceeded) {
+		return ValCurs{}, fmt.Errorf("Превышено время загрузки курсов за день (%s): %w", timeout, err)
+	}
+	return valCurs, err
+}
+
+// FetchBatches загружает курсы валют так же, как FetchAll, но разбивает даты на пакеты
+// по batchSize дат: следующий пакет запрашивается только после загрузки предыдущего
+// и паузы pause. При batchSize <= 0 все даты загружаются одним пакетом.
+func FetchBatches(ctx context.Context, source RateSource, dates []time.Time, concurrency int, timeout time.Duration, batchSize int, pause time.Duration) <-chan FetchResult {
+	if batchSize <= 0 || batchSize >= len(dates) {
+		return FetchAll(ctx, source, dates, concurrency, timeout)
+	}
+
+	results := make(chan FetchResult)
+	go func() {
+		defer close(results)
+		for start := 0; start < len(dates); start += batchSize {
+			if start > 0 {
+				select {
+				case <-time.After(pause):
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			end := min(start+batchSize, len(dates))
+			for result := range FetchAll(ctx, source, dates[start:end], concurrency, timeout) {
+				results <- result
+			}
+		}
+	}()
+	return results
+}
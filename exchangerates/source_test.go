@@ -0,0 +1,251 @@
+package exchangerates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSource возвращает заранее заданные курсы за даты с задержкой, имитирующей запрос к API;
+// при нулевой задержке курсы возвращаются сразу, без проверки контекста
+type fakeSource struct {
+	days  map[time.Time]ValCurs
+	delay time.Duration
+	calls atomic.Int32
+}
+
+// newFakeSource создаёт источник с курсами USD и EUR за n дней начиная с start
+func newFakeSource(start time.Time, n int, delay time.Duration) *fakeSource {
+	s := &fakeSource{days: make(map[time.Time]ValCurs), delay: delay}
+	for i := 0; i < n; i++ {
+		date := start.AddDate(0, 0, i)
+		s.days[date] = newDay(date, map[string]string{
+			"USD": strconv.FormatFloat(90+float64(i%7)/10, 'f', -1, 64),
+			"EUR": strconv.FormatFloat(98-float64(i%5)/10, 'f', -1, 64),
+		})
+	}
+	return s
+}
+
+func (s *fakeSource) BaseCurrency() string {
+	return BaseRUB
+}
+
+func (s *fakeSource) FetchRates(ctx context.Context, date time.Time) (ValCurs, error) {
+	s.calls.Add(1)
+	if s.delay > 0 {
+		select {
+		case <-time.After(s.delay):
+		case <-ctx.Done():
+			return ValCurs{}, ctx.Err()
+		}
+	}
+	valCurs, ok := s.days[date]
+	if !ok {
+		return ValCurs{}, fmt.Errorf("нет курсов за %s", date.Format(isoDateLayout))
+	}
+	valCurs.Request = date
+	return valCurs, nil
+}
+
+// testDates возвращает n последовательных дат начиная с start
+func testDates(start time.Time, n int) []time.Time {
+	dates := make([]time.Time, n)
+	for i := range dates {
+		dates[i] = start.AddDate(0, 0, i)
+	}
+	return dates
+}
+
+// fetchSequential загружает курсы за даты по одной и учитывает их в store
+func fetchSequential(source RateSource, dates []time.Time, store *StatsStore) {
+	for _, d := range dates {
+		valCurs, err := source.FetchRates(context.Background(), d)
+		if err == nil {
+			store.Update(valCurs)
+		}
+	}
+}
+
+// fetchConcurrent загружает курсы через FetchAll и учитывает их в store
+func fetchConcurrent(source RateSource, dates []time.Time, store *StatsStore) {
+	for result := range FetchAll(context.Background(), source, dates, DefaultConcurrency, 0) {
+		if result.Err == nil {
+			store.Update(result.ValCurs)
+		}
+	}
+}
+
+func TestFetchAllMatchesSequential(t *testing.T) {
+	start := testDate(time.January, 1)
+	dates := testDates(start, 40)
+	source := newFakeSource(start, 40, time.Millisecond)
+
+	// Точное среднее не зависит от порядка поступления курсов
+	sequential, concurrent := NewStatsStore(), NewStatsStore()
+	sequential.SetExact(true)
+	concurrent.SetExact(true)
+	fetchSequential(source, dates, sequential)
+	fetchConcurrent(source, dates, concurrent)
+
+	want, got := sequential.Snapshot(), concurrent.Snapshot()
+	if len(got) != 2 {
+		t.Fatalf("len(stats) = %d, want 2", len(got))
+	}
+	for code, w := range want {
+		g := got[code]
+		// Сумма float64 зависит от порядка сложения, поэтому сравнивается с допуском
+		if math.Abs(g.TotalValue-w.TotalValue) > 1e-9 {
+			t.Errorf("%s: TotalValue = %v, want %v", code, g.TotalValue, w.TotalValue)
+		}
+		g.TotalValue = w.TotalValue
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: статистика параллельной загрузки отличается от последовательной:\n%+v\n%+v", code, g, w)
+		}
+	}
+}
+
+func TestFetchBatches(t *testing.T) {
+	start := testDate(time.January, 1)
+	source := newFakeSource(start, 5, 0)
+	dates := testDates(start, 5) // Пакеты по две даты: [1, 2], [3, 4], [5]
+
+	seen := make(map[int]bool) // Номера пакетов, результаты которых уже получены
+	lastBatch := 0
+	for result := range FetchBatches(context.Background(), source, dates, 4, 0, 2, 0) {
+		if result.Err != nil {
+			t.Fatalf("%s: %v", result.Date.Format(isoDateLayout), result.Err)
+		}
+		batch := int(result.Date.Sub(start).Hours()/24) / 2
+		if batch < lastBatch {
+			t.Errorf("дата %s пакета %d получена после пакета %d", result.Date.Format(isoDateLayout), batch, lastBatch)
+		}
+		lastBatch = batch
+		// Пока не получены результаты пакета, следующий пакет не запрашивается
+		if !seen[batch] {
+			seen[batch] = true
+			if calls, limit := int(source.calls.Load()), min(2*(batch+1), len(dates)); calls > limit {
+				t.Errorf("пакет %d: calls = %d, want не больше %d", batch+1, calls, limit)
+			}
+		}
+	}
+	if len(seen) != 3 || source.calls.Load() != 5 {
+		t.Fatalf("пакетов = %d, calls = %d, want 3 и 5", len(seen), source.calls.Load())
+	}
+}
+
+func TestFetchBatchesPause(t *testing.T) {
+	start := testDate(time.January, 1)
+	source := newFakeSource(start, 5, 0)
+
+	// Пауза заведомо длиннее теста: следующий пакет не запрашивается до её окончания,
+	// а отмена контекста во время паузы завершает загрузку
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var received int
+	for result := range FetchBatches(ctx, source, testDates(start, 5), 4, 0, 2, time.Hour) {
+		if result.Err != nil {
+			t.Fatalf("%s: %v", result.Date.Format(isoDateLayout), result.Err)
+		}
+		if received++; received == 2 {
+			cancel()
+		}
+	}
+	if received != 2 || source.calls.Load() != 2 {
+		t.Errorf("received = %d, calls = %d, want 2 и 2", received, source.calls.Load())
+	}
+}
+
+// slowDateSource не отвечает за дату slow, пока не закрыт канал others и не отменён контекст запроса
+type slowDateSource struct {
+	*fakeSource
+	slow     time.Time
+	others   chan struct{} // Закрывается после получения результатов за остальные даты
+	released atomic.Bool   // Запрос за дату slow завершён после отмены контекста
+}
+
+func (s *slowDateSource) FetchRates(ctx context.Context, date time.Time) (ValCurs, error) {
+	if !date.Equal(s.slow) {
+		return s.fakeSource.FetchRates(ctx, date)
+	}
+	<-s.others
+	select {
+	case <-ctx.Done():
+		s.released.Store(true)
+		return ValCurs{}, ctx.Err()
+	case <-time.After(10 * time.Second):
+		return ValCurs{}, fmt.Errorf("запрос за %s не отменён", date.Format(isoDateLayout))
+	}
+}
+
+func TestFetchAllDayTimeout(t *testing.T) {
+	start := testDate(time.January, 1)
+	source := &slowDateSource{fakeSource: newFakeSource(start, 6, 0), slow: start.AddDate(0, 0, 1), others: make(chan struct{})}
+
+	var order []time.Time // Даты в порядке получения результатов
+	for result := range FetchAll(context.Background(), source, testDates(start, 6), 2, 10*time.Millisecond) {
+		order = append(order, result.Date)
+		if result.Date.Equal(source.slow) {
+			if !errors.Is(result.Err, context.DeadlineExceeded) {
+				t.Errorf("%s: err = %v, want context.DeadlineExceeded", result.Date.Format(isoDateLayout), result.Err)
+			}
+			continue
+		}
+		if result.Err != nil {
+			t.Errorf("%s: %v", result.Date.Format(isoDateLayout), result.Err)
+		}
+		// Остальные даты загружаются второй горутиной, пока первая ожидает медленный ответ
+		if len(order) == 5 {
+			close(source.others)
+		}
+	}
+
+	if !source.released.Load() {
+		t.Error("запрос за медленную дату не освобождён по истечении времени")
+	}
+	if len(order) != 6 || !order[len(order)-1].Equal(source.slow) {
+		t.Errorf("order = %v, want медленная дата последней", order)
+	}
+}
+
+func TestFetchAllReportsEveryDate(t *testing.T) {
+	start := testDate(time.January, 1)
+	source := newFakeSource(start, 5, 0)
+	dates := testDates(start, 7) // Две последние даты отсутствуют в источнике
+
+	seen := make(map[time.Time]bool)
+	var failed int
+	for result := range FetchAll(context.Background(), source, dates, 3, 0) {
+		seen[result.Date] = true
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if len(seen) != 7 || failed != 2 {
+		t.Errorf("results = %d, failed = %d, want 7 и 2", len(seen), failed)
+	}
+}
+
+func BenchmarkFetchSequential(b *testing.B) {
+	start := testDate(time.January, 1)
+	dates := testDates(start, 30)
+	source := newFakeSource(start, 30, time.Millisecond)
+	for i := 0; i < b.N; i++ {
+		fetchSequential(source, dates, NewStatsStore())
+	}
+}
+
+func BenchmarkFetchConcurrent(b *testing.B) {
+	start := testDate(time.January, 1)
+	dates := testDates(start, 30)
+	source := newFakeSource(start, 30, time.Millisecond)
+	for i := 0; i < b.N; i++ {
+		fetchConcurrent(source, dates, NewStatsStore())
+	}
+}
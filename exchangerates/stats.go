@@ -0,0 +1,666 @@
+package exchangerates
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CurrencyStats хранит статистику по курсам валюты
+type CurrencyStats struct {
+	MaxValue     float64     `json:"max_value"`          // Максимальное значение курса
+	MinValue     float64     `json:"min_value"`          // Минимальное значение курса
+	MaxDate      string      `json:"max_date"`           // Дата максимального курса; при равных значениях — самая поздняя
+	MinDate      string      `json:"min_date"`           // Дата минимального курса; при равных значениях — самая ранняя
+	TotalValue   float64     `json:"-"`                  // Суммарное значение курса для расчета среднего
+	Count        int         `json:"count"`              // Количество записей для расчета среднего
+	Average      float64     `json:"average"`            // Среднее значение курса
+	Nominal      int         `json:"nominal"`            // Номинал валюты
+	CurrencyName string      `json:"name"`               // Название валюты
+	NumCode      string      `json:"num_code"`           // Цифровой код валюты
+	CharCode     string      `json:"char_code"`          // Символьный код валюты
+	Series       []RatePoint `json:"-"`                  // Значения курса по датам в хронологическом порядке
+	DaysInRange  int         `json:"days_in_range"`      // Количество учтённых дат периода по всем валютам
+	PerUnit      bool        `json:"per_unit,omitempty"` // Курсы пересчитаны за одну единицу валюты (см. ToUnit)
+
+	maxTime    time.Time // Дата максимального курса для сравнения при равных значениях
+	minTime    time.Time // Дата минимального курса для сравнения при равных значениях
+	exactTotal *big.Rat  // Точная сумма значений курса; задаётся при точном расчёте среднего (см. StatsStore.SetExact)
+}
+
+// RatePoint содержит значение курса валюты за одну дату
+type RatePoint struct {
+	Date  time.Time `json:"date"`  // Дата курса
+	Value float64   `json:"value"` // Значение курса
+
+	exact *big.Rat // Точное значение курса из ответа; задаётся при точном расчёте среднего (см. StatsStore.SetExact)
+}
+
+// mean возвращает среднее значение курса за период. Если накоплена точная сумма значений
+// (см. StatsStore.SetExact), в float64 преобразуется только итоговое среднее.
+func (s CurrencyStats) mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	if s.exactTotal != nil {
+		average, _ := new(big.Rat).Quo(s.exactTotal, big.NewRat(int64(s.Count), 1)).Float64()
+		return average
+	}
+	return s.TotalValue / float64(s.Count)
+}
+
+// Values возвращает значения курса за период в хронологическом порядке
+func (s CurrencyStats) Values() []float64 {
+	values := make([]float64, len(s.Series))
+	for i, p := range s.Series {
+		values[i] = p.Value
+	}
+	return values
+}
+
+// Median возвращает медиану значений курса за период
+func (s CurrencyStats) Median() float64 {
+	sorted := s.Values()
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// StdDev возвращает стандартное отклонение значений курса за период (по генеральной совокупности)
+func (s CurrencyStats) StdDev() float64 {
+	values := s.Values()
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sq / float64(len(values)))
+}
+
+// Volatility возвращает размах колебаний курса относительно среднего: (Max - Min) / Average
+func (s CurrencyStats) Volatility() float64 {
+	if s.Average == 0 {
+		return 0
+	}
+	return (s.MaxValue - s.MinValue) / s.Average
+}
+
+// perUnit пересчитывает значение курса на одну единицу валюты с учётом номинала
+func (s CurrencyStats) perUnit(v float64) float64 {
+	if s.Nominal <= 0 {
+		return 0
+	}
+	return v / float64(s.Nominal)
+}
+
+// perUnitExact пересчитывает точное значение курса на одну единицу валюты; nil остаётся nil
+func (s CurrencyStats) perUnitExact(v *big.Rat) *big.Rat {
+	if v == nil {
+		return nil
+	}
+	return new(big.Rat).Quo(v, big.NewRat(int64(s.Nominal), 1))
+}
+
+// ToUnit возвращает копию статистики, в которой все курсы пересчитаны за одну единицу
+// валюты, номинал равен 1, а PerUnit установлен
+func (s CurrencyStats) ToUnit() CurrencyStats {
+	if s.PerUnit || s.Nominal <= 0 {
+		return s
+	}
+
+	s.MaxValue, s.MinValue = s.perUnit(s.MaxValue), s.perUnit(s.MinValue)
+	s.TotalValue, s.Average = s.perUnit(s.TotalValue), s.perUnit(s.Average)
+	s.exactTotal = s.perUnitExact(s.exactTotal)
+	series := make([]RatePoint, len(s.Series))
+	for i, p := range s.Series {
+		series[i] = RatePoint{Date: p.Date, Value: s.perUnit(p.Value), exact: s.perUnitExact(p.exact)}
+	}
+	s.Series = series
+	s.Nominal, s.PerUnit = 1, true
+	return s
+}
+
+// UnitAverage возвращает средний курс одной единицы валюты (Average / Nominal)
+func (s CurrencyStats) UnitAverage() float64 {
+	return s.perUnit(s.Average)
+}
+
+// UnitMax возвращает максимальный курс одной единицы валюты (MaxValue / Nominal)
+func (s CurrencyStats) UnitMax() float64 {
+	return s.perUnit(s.MaxValue)
+}
+
+// UnitMin возвращает минимальный курс одной единицы валюты (MinValue / Nominal)
+func (s CurrencyStats) UnitMin() float64 {
+	return s.perUnit(s.MinValue)
+}
+
+// First возвращает значение курса за первую дату периода
+func (s CurrencyStats) First() float64 {
+	if len(s.Series) == 0 {
+		return 0
+	}
+	return s.Series[0].Value
+}
+
+// Last возвращает значение курса за последнюю дату периода
+func (s CurrencyStats) Last() float64 {
+	if len(s.Series) == 0 {
+		return 0
+	}
+	return s.Series[len(s.Series)-1].Value
+}
+
+// Change возвращает абсолютное изменение курса между первой и последней датой периода
+func (s CurrencyStats) Change() float64 {
+	return s.Last() - s.First()
+}
+
+// ChangePercent возвращает изменение курса между первой и последней датой периода в процентах
+func (s CurrencyStats) ChangePercent() float64 {
+	first := s.First()
+	if first == 0 {
+		return 0
+	}
+	return s.Change() / first * 100
+}
+
+// MovingAverage возвращает ряд скользящих средних курса по окну из window последовательных
+// значений ряда Series. Каждая точка ряда соответствует последней дате окна.
+// Если значений меньше window или window <= 0, возвращается nil.
+func (s CurrencyStats) MovingAverage(window int) []RatePoint {
+	if window <= 0 || len(s.Series) < window {
+		return nil
+	}
+
+	averages := make([]RatePoint, 0, len(s.Series)-window+1)
+	var sum float64
+	for i, p := range s.Series {
+		sum += p.Value
+		if i >= window {
+			sum -= s.Series[i-window].Value
+		}
+		if i >= window-1 {
+			averages = append(averages, RatePoint{Date: p.Date, Value: sum / float64(window)})
+		}
+	}
+	return averages
+}
+
+// Outliers возвращает значения курса, отличающиеся от предыдущего значения ряда более чем
+// на threshold процентов. Сравнение ведётся с последним значением, не признанным выбросом,
+// поэтому возврат курса к обычному уровню после однодневного скачка выбросом не считается.
+func (s CurrencyStats) Outliers(threshold float64) []RatePoint {
+	if threshold <= 0 || len(s.Series) == 0 {
+		return nil
+	}
+
+	var outliers []RatePoint
+	prev := s.Series[0].Value
+	for _, p := range s.Series[1:] {
+		if prev != 0 && math.Abs(p.Value-prev)/prev*100 > threshold {
+			outliers = append(outliers, p)
+			continue
+		}
+		prev = p.Value
+	}
+	return outliers
+}
+
+// recompute пересчитывает сумму, количество, минимум и максимум курса по ряду Series.
+// Точная сумма пересчитывается, только если точные значения есть у всех значений ряда.
+func (s *CurrencyStats) recompute() {
+	s.TotalValue = 0
+	s.Count = len(s.Series)
+	s.exactTotal = nil
+	if len(s.Series) > 0 {
+		s.exactTotal = new(big.Rat)
+	}
+	for i, p := range s.Series {
+		s.TotalValue += p.Value
+		if s.exactTotal != nil && p.exact != nil {
+			s.exactTotal = new(big.Rat).Add(s.exactTotal, p.exact)
+		} else {
+			s.exactTotal = nil
+		}
+		// Ряд упорядочен по дате, поэтому при равных значениях максимум получает самую позднюю дату,
+		// а минимум — самую раннюю
+		if i == 0 || p.Value >= s.MaxValue {
+			s.MaxValue, s.MaxDate, s.maxTime = p.Value, p.Date.Format(valCursDateLayout), p.Date
+		}
+		if i == 0 || p.Value < s.MinValue {
+			s.MinValue, s.MinDate, s.minTime = p.Value, p.Date.Format(valCursDateLayout), p.Date
+		}
+	}
+}
+
+// ChangePoint описывает изменение курса валюты по сравнению с предыдущей датой ряда
+type ChangePoint struct {
+	Date     time.Time `json:"date"`      // Дата, начиная с которой действует новый курс
+	OldValue float64   `json:"old_value"` // Курс за предыдущую дату
+	NewValue float64   `json:"new_value"` // Новый курс
+}
+
+// Changes возвращает даты, в которые курс отличается от курса за предыдущую дату ряда.
+// Подряд идущие одинаковые значения схлопываются, первая дата периода не включается.
+func (s CurrencyStats) Changes() []ChangePoint {
+	var changes []ChangePoint
+	for i := 1; i < len(s.Series); i++ {
+		prev, cur := s.Series[i-1], s.Series[i]
+		if cur.Value != prev.Value {
+			changes = append(changes, ChangePoint{Date: cur.Date, OldValue: prev.Value, NewValue: cur.Value})
+		}
+	}
+	return changes
+}
+
+// Trend описывает направление изменения курса за период
+type Trend string
+
+// Возможные направления изменения курса
+const (
+	TrendUp   Trend = "up"   // Курс вырос
+	TrendDown Trend = "down" // Курс снизился
+	TrendFlat Trend = "flat" // Курс изменился не более чем на FlatThreshold процентов
+)
+
+// FlatThreshold задаёт максимальное изменение курса в процентах, при котором курс считается неизменным
+const FlatThreshold = 0.1
+
+// Trend возвращает направление изменения курса между первой и последней датой периода
+func (s CurrencyStats) Trend() Trend {
+	change := s.ChangePercent()
+	switch {
+	case change > FlatThreshold:
+		return TrendUp
+	case change < -FlatThreshold:
+		return TrendDown
+	default:
+		return TrendFlat
+	}
+}
+
+// Coverage возвращает долю учтённых дат периода, за которые есть курс валюты (от 0 до 1)
+func (s CurrencyStats) Coverage() float64 {
+	if s.DaysInRange == 0 {
+		return 0
+	}
+	return float64(s.Count) / float64(s.DaysInRange)
+}
+
+// Complete сообщает, есть ли курс валюты за каждую учтённую дату периода.
+// ЦБ РФ добавляет и исключает валюты, поэтому часть валют присутствует не во всех днях.
+func (s CurrencyStats) Complete() bool {
+	return s.Count >= s.DaysInRange
+}
+
+// SortedStats возвращает статистику по валютам, упорядоченную по символьному коду
+func SortedStats(stats map[string]CurrencyStats) []CurrencyStats {
+	list := make([]CurrencyStats, 0, len(stats))
+	for _, s := range stats {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CharCode < list[j].CharCode
+	})
+	return list
+}
+
+// StatsStore хранит статистику по валютам и допускает конкурентный доступ
+type StatsStore struct {
+	mu     sync.RWMutex
+	stats  map[string]*CurrencyStats // Статистика по символьному коду валюты
+	filter map[string]bool           // Учитываемые валюты; пустой фильтр означает все валюты
+	seen   map[string]bool           // Уже учтённые даты курсов (атрибут Date) с корректным форматом
+	latest map[string]time.Time      // Последняя учтённая дата курса по символьному коду валюты
+	exact  bool                      // Среднее значение рассчитывается точно (см. SetExact)
+	base   string                    // Валюта, в которой выражены учтённые курсы (см. ValCurs.BaseCurrency)
+	gauge  bool                      // Курсы публикуются в метрике exchange_rates_value (см. SetMetrics)
+}
+
+// NewStatsStore создаёт пустое хранилище статистики
+func NewStatsStore() *StatsStore {
+	return &StatsStore{
+		stats:  make(map[string]*CurrencyStats),
+		seen:   make(map[string]bool),
+		latest: make(map[string]time.Time),
+	}
+}
+
+// SetFilter ограничивает учёт статистики указанными символьными кодами валют.
+// Пустой список снимает ограничение.
+func (s *StatsStore) SetFilter(codes []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filter = make(map[string]bool, len(codes))
+	for _, code := range codes {
+		s.filter[strings.ToUpper(code)] = true
+	}
+}
+
+// SetExact включает точный расчёт среднего значения курса: значения из ответа (Valute.Value)
+// суммируются как рациональные числа, и в float64 преобразуется только итоговое среднее.
+// Должен вызываться до учёта курсов: точная сумма накапливается только по курсам, учтённым после включения.
+func (s *StatsStore) SetExact(exact bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.exact = exact
+}
+
+// SetMetrics включает публикацию курса за самую позднюю учтённую дату в метрике exchange_rates_value.
+// Метрика общая для процесса, поэтому включается только для хранилища основного периода
+// с курсами в валюте источника: хранилища периода сравнения или пересчитанных курсов перезаписали бы её.
+func (s *StatsStore) SetMetrics(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gauge = enabled
+}
+
+// Update анализирует данные о курсах валют и обновляет статистику.
+// Повторные данные за уже учтённую дату игнорируются: в нерабочие дни ЦБ РФ
+// возвращает курсы предыдущего рабочего дня, и они не должны учитываться дважды.
+func (s *StatsStore) Update(valCurs ValCurs) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen[valCurs.Date] {
+		return
+	}
+	date := valCurs.Time
+	if date.IsZero() {
+		slog.Warn("Пропуск дня без разобранной даты", "date", valCurs.Date)
+		return
+	}
+	s.seen[valCurs.Date] = true
+	s.base = valCurs.BaseCurrency()
+
+	for _, valute := range valCurs.Valutes {
+		if valute.CharCode == "" || valute.NumCode == "" || valute.Nominal <= 0 {
+			slog.Warn("Пропуск валюты с некорректными кодами или номиналом", "date", valCurs.Date,
+				"id", valute.ID, "char_code", valute.CharCode, "num_code", valute.NumCode, "nominal", valute.Nominal)
+			continue
+		}
+		valute = normalizeCodes(valCurs.Date, valute)
+		if len(s.filter) > 0 && !s.filter[valute.CharCode] {
+			continue
+		}
+
+		value, err := valute.FloatValue()
+		if err != nil {
+			logSkippedValue(valCurs.Date, err)
+			continue
+		}
+		var exact *big.Rat
+		if s.exact {
+			if exact, err = valute.ratValue(); err != nil {
+				logSkippedValue(valCurs.Date, err)
+				continue
+			}
+		}
+		point := RatePoint{Date: date, Value: value, exact: exact}
+
+		// Метрика хранит значение курса за самую позднюю из обработанных дат
+		if !date.Before(s.latest[valute.CharCode]) {
+			s.latest[valute.CharCode] = date
+			if s.gauge {
+				rateValue.WithLabelValues(valute.CharCode).Set(value)
+			}
+		}
+
+		// Добавление или обновление статистики по валюте
+		stats, ok := s.stats[valute.CharCode]
+		if !ok {
+			s.stats[valute.CharCode] = &CurrencyStats{
+				MaxValue:     value,
+				MinValue:     value,
+				MaxDate:      valCurs.Date,
+				MinDate:      valCurs.Date,
+				TotalValue:   value,
+				exactTotal:   exact,
+				Count:        1,
+				Nominal:      valute.Nominal,
+				CurrencyName: valute.Name,
+				NumCode:      valute.NumCode,
+				CharCode:     valute.CharCode,
+				Series:       []RatePoint{point},
+				maxTime:      date,
+				minTime:      date,
+			}
+		} else {
+			stats.TotalValue += value
+			if stats.exactTotal != nil && exact != nil {
+				stats.exactTotal = new(big.Rat).Add(stats.exactTotal, exact)
+			} else {
+				stats.exactTotal = nil // Курсы, учтённые без точного значения, не позволяют рассчитать точное среднее
+			}
+			stats.Count++
+			stats.Series = insertPoint(stats.Series, point)
+			// Дни обрабатываются в произвольном порядке, поэтому при равных значениях
+			// дата выбирается детерминированно: самая поздняя для максимума и самая ранняя для минимума
+			if value > stats.MaxValue || value == stats.MaxValue && date.After(stats.maxTime) {
+				stats.MaxValue = value
+				stats.MaxDate = valCurs.Date
+				stats.maxTime = date
+			}
+			if value < stats.MinValue || value == stats.MinValue && date.Before(stats.minTime) {
+				stats.MinValue = value
+				stats.MinDate = valCurs.Date
+				stats.minTime = date
+			}
+		}
+	}
+}
+
+// insertPoint вставляет значение курса в ряд, сохраняя хронологический порядок
+func insertPoint(series []RatePoint, p RatePoint) []RatePoint {
+	i := sort.Search(len(series), func(i int) bool {
+		return series[i].Date.After(p.Date)
+	})
+	series = append(series, RatePoint{})
+	copy(series[i+1:], series[i:])
+	series[i] = p
+	return series
+}
+
+// Snapshot возвращает копию накопленной статистики с рассчитанным средним значением курса
+func (s *StatsStore) Snapshot() map[string]CurrencyStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot := make(map[string]CurrencyStats, len(s.stats))
+	for code, stats := range s.stats {
+		c := *stats
+		c.Series = append([]RatePoint(nil), stats.Series...)
+		c.Average = c.mean() // Расчёт среднего значения курса
+		c.DaysInRange = len(s.seen)
+		snapshot[code] = c
+	}
+	return snapshot
+}
+
+// DropOutliers исключает из статистики значения курсов, признанные выбросами (см. CurrencyStats.Outliers),
+// и возвращает исключённые значения по символьному коду валюты
+func (s *StatsStore) DropOutliers(threshold float64) map[string][]RatePoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dropped := make(map[string][]RatePoint)
+	for code, stats := range s.stats {
+		outliers := stats.Outliers(threshold)
+		if len(outliers) == 0 {
+			continue
+		}
+
+		dropped[code] = outliers
+		series := make([]RatePoint, 0, len(stats.Series)-len(outliers))
+		for _, p := range stats.Series {
+			if len(outliers) > 0 && p.Date.Equal(outliers[0].Date) {
+				outliers = outliers[1:]
+				continue
+			}
+			series = append(series, p)
+		}
+		stats.Series = series
+		stats.recompute()
+	}
+	return dropped
+}
+
+// Interval задаёт длину интервала группировки курсов
+type Interval string
+
+// Поддерживаемые интервалы группировки курсов
+const (
+	IntervalDay   Interval = "day"   // Календарный день
+	IntervalWeek  Interval = "week"  // Неделя с понедельника по воскресенье
+	IntervalMonth Interval = "month" // Календарный месяц
+)
+
+// ParseInterval разбирает название интервала группировки (day, week или month)
+func ParseInterval(name string) (Interval, error) {
+	switch i := Interval(strings.ToLower(name)); i {
+	case IntervalDay, IntervalWeek, IntervalMonth:
+		return i, nil
+	default:
+		return "", fmt.Errorf("Неизвестный интервал группировки %q, ожидается day, week или month", name)
+	}
+}
+
+// bounds возвращает первый и последний день интервала, содержащего дату t
+func (i Interval) bounds(t time.Time) (time.Time, time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch i {
+	case IntervalWeek:
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7) // Неделя начинается с понедельника
+		return start, start.AddDate(0, 0, 6)
+	case IntervalMonth:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, -1)
+	default:
+		return day, day
+	}
+}
+
+// IntervalStats содержит статистику по валютам за один интервал группировки
+type IntervalStats struct {
+	Start time.Time                // Первый день интервала
+	End   time.Time                // Последний день интервала
+	Stats map[string]CurrencyStats // Статистика по символьному коду валюты
+}
+
+// GroupByInterval разбивает ряды курсов валют на интервалы interval и рассчитывает
+// статистику по каждому интервалу. Интервалы возвращаются в хронологическом порядке.
+func GroupByInterval(stats map[string]CurrencyStats, interval Interval) []IntervalStats {
+	points := make(map[time.Time]map[string][]RatePoint) // Значения курсов по началу интервала и валюте
+	days := make(map[time.Time]map[time.Time]bool)       // Учтённые даты по началу интервала
+	for code, s := range stats {
+		for _, p := range s.Series {
+			start, _ := interval.bounds(p.Date)
+			if points[start] == nil {
+				points[start] = make(map[string][]RatePoint)
+				days[start] = make(map[time.Time]bool)
+			}
+			points[start][code] = append(points[start][code], p)
+			days[start][p.Date] = true
+		}
+	}
+
+	groups := make([]IntervalStats, 0, len(points))
+	for start, byCode := range points {
+		_, end := interval.bounds(start)
+		group := IntervalStats{Start: start, End: end, Stats: make(map[string]CurrencyStats, len(byCode))}
+		for code, series := range byCode {
+			c := stats[code]
+			c.Series = series
+			c.recompute()
+			c.Average = c.mean()
+			c.DaysInRange = len(days[start])
+			group.Stats[code] = c
+		}
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Start.Before(groups[j].Start)
+	})
+	return groups
+}
+
+// LatestValue возвращает курс валюты code за самую позднюю учтённую дату и эту дату
+// в формате атрибута Date ответа ЦБ РФ (ДД.ММ.ГГГГ)
+func (s *StatsStore) LatestValue(code string) (float64, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	code = strings.ToUpper(code)
+	stats, ok := s.stats[code]
+	if !ok || len(stats.Series) == 0 {
+		return 0, "", fmt.Errorf("Нет данных о курсе валюты %s", code)
+	}
+	last := stats.Series[len(stats.Series)-1]
+	return last.Value, last.Date.Format(valCursDateLayout), nil
+}
+
+// Base возвращает символьный код валюты, в которой выражены учтённые курсы,
+// или пустую строку, если курсы ещё не учитывались
+func (s *StatsStore) Base() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.base
+}
+
+// basePerUnit возвращает средний курс одной единицы валюты в базовой валюте хранилища с учётом номинала.
+// Курс самой базовой валюты равен 1; после пересчёта к другой базовой валюте (см. Rebase)
+// исходная базовая валюта учитывается как обычная валюта.
+func (s *StatsStore) basePerUnit(code string) (float64, error) {
+	code = strings.ToUpper(code)
+	stats, ok := s.stats[code]
+	if !ok && code == s.base {
+		return 1, nil
+	}
+	if !ok || stats.Count == 0 || stats.Nominal <= 0 {
+		return 0, fmt.Errorf("Нет данных о курсе валюты %s", code)
+	}
+	return stats.mean() / float64(stats.Nominal), nil
+}
+
+// Convert пересчитывает сумму из одной валюты в другую по средним курсам за период.
+// Базовая валюта хранилища (см. Base) поддерживается без загрузки курса.
+func (s *StatsStore) Convert(amount float64, from, to string) (float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fromRate, err := s.basePerUnit(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := s.basePerUnit(to)
+	if err != nil {
+		return 0, err
+	}
+	return amount * fromRate / toRate, nil
+}
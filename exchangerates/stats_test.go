@@ -0,0 +1,544 @@
+package exchangerates
+
+import (
+	"bytes"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testDate возвращает дату 2024 года по номеру месяца и дня
+func testDate(month time.Month, day int) time.Time {
+	return time.Date(2024, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// newDay возвращает курсы ЦБ РФ за дату с номиналом 1 по символьному коду валюты
+func newDay(date time.Time, values map[string]string) ValCurs {
+	valCurs := ValCurs{Date: date.Format(valCursDateLayout), Time: date}
+	for code, value := range values {
+		valCurs.Valutes = append(valCurs.Valutes, Valute{
+			ID:       "R" + currencyNumCodes[code],
+			NumCode:  currencyNumCodes[code],
+			CharCode: code,
+			Nominal:  1,
+			Name:     code,
+			Value:    value,
+		})
+	}
+	return valCurs
+}
+
+func TestExactAverage(t *testing.T) {
+	// Сумма десяти значений 90,1 в float64 накапливает ошибку округления
+	float, exact := NewStatsStore(), NewStatsStore()
+	exact.SetExact(true)
+	for i := 1; i <= 10; i++ {
+		day := newDay(testDate(time.February, i), map[string]string{"USD": "90,1"})
+		float.Update(day)
+		exact.Update(day)
+	}
+
+	if got := float.Snapshot()["USD"].Average; got == 90.1 {
+		t.Fatalf("float Average = %v: ряд не выявляет ошибку округления", got)
+	}
+	if got := exact.Snapshot()["USD"].Average; got != 90.1 {
+		t.Errorf("exact Average = %v, want 90.1", got)
+	}
+
+	got, err := exact.Convert(10, "USD", BaseRUB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 901 {
+		t.Errorf("Convert(10, USD, RUB) = %v, want 901", got)
+	}
+
+	groups := GroupByInterval(exact.Snapshot(), IntervalWeek)
+	for _, g := range groups {
+		if avg := g.Stats["USD"].Average; avg != 90.1 {
+			t.Errorf("Average за неделю с %s = %v, want 90.1", g.Start.Format(isoDateLayout), avg)
+		}
+	}
+}
+
+func TestExactAverageAfterDropOutliers(t *testing.T) {
+	store := NewStatsStore()
+	store.SetExact(true)
+	for i := 1; i <= 10; i++ {
+		store.Update(newDay(testDate(time.February, i), map[string]string{"USD": "90,1"}))
+	}
+	store.Update(newDay(testDate(time.February, 11), map[string]string{"USD": "180,2"}))
+
+	if dropped := store.DropOutliers(50); len(dropped["USD"]) != 1 {
+		t.Fatalf("DropOutliers = %v, want one outlier", dropped)
+	}
+	if got := store.Snapshot()["USD"].Average; got != 90.1 {
+		t.Errorf("Average = %v, want 90.1", got)
+	}
+}
+
+func TestExactAverageOrderIndependent(t *testing.T) {
+	values := []string{"0,1", "0,2", "0,3", "1000000,7", "0,0001"}
+	var averages []float64
+	for _, order := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {3, 0, 4, 1, 2}} {
+		store := NewStatsStore()
+		store.SetExact(true)
+		for _, i := range order {
+			store.Update(newDay(testDate(time.March, i+1), map[string]string{"USD": values[i]}))
+		}
+		averages = append(averages, store.Snapshot()["USD"].Average)
+	}
+	for _, avg := range averages {
+		if avg != 200000.26002 {
+			t.Errorf("Average = %v, want 200000.26002 в любом порядке учёта (%v)", avg, averages)
+		}
+	}
+}
+
+func TestStatsStoreConcurrentUpdate(t *testing.T) {
+	// Запускать с -race: обновления из разных горутин не должны приводить к гонкам
+	const days = 200
+	store := NewStatsStore()
+	var wg sync.WaitGroup
+	for i := 0; i < days; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.Update(newDay(testDate(time.January, 1).AddDate(0, 0, i), map[string]string{
+				"USD": strconv.Itoa(90 + i%10),
+				"EUR": "100",
+			}))
+			store.Snapshot()
+		}(i)
+	}
+	wg.Wait()
+
+	stats := store.Snapshot()
+	usd := stats["USD"]
+	// Значения 90..99 повторяются 20 раз: сумма 20 * 945
+	if usd.Count != days || usd.TotalValue != 20*945 {
+		t.Errorf("USD Count = %d, TotalValue = %v, want %d и %d", usd.Count, usd.TotalValue, days, 20*945)
+	}
+	if usd.MaxValue != 99 || usd.MinValue != 90 {
+		t.Errorf("USD Max = %v, Min = %v, want 99 и 90", usd.MaxValue, usd.MinValue)
+	}
+	if eur := stats["EUR"]; eur.Count != days || eur.Average != 100 {
+		t.Errorf("EUR Count = %d, Average = %v, want %d и 100", eur.Count, eur.Average, days)
+	}
+	if len(usd.Series) != days {
+		t.Errorf("len(Series) = %d, want %d", len(usd.Series), days)
+	}
+}
+
+func TestStatsStoreFilter(t *testing.T) {
+	store := NewStatsStore()
+	store.SetFilter([]string{"usd", "EUR", "XYZ"}) // Неизвестный код не мешает учёту остальных
+	store.Update(newDay(testDate(time.March, 1), map[string]string{"USD": "90", "EUR": "98", "JPY": "60"}))
+
+	stats := store.Snapshot()
+	if len(stats) != 2 || stats["USD"].Count != 1 || stats["EUR"].Count != 1 {
+		t.Errorf("stats = %v, want только USD и EUR", SortedStats(stats))
+	}
+}
+
+// seriesStats возвращает статистику с рядом значений курса по последовательным дням
+func seriesStats(values ...float64) CurrencyStats {
+	var s CurrencyStats
+	for i, v := range values {
+		s.Series = append(s.Series, RatePoint{Date: testDate(time.January, 1+i), Value: v})
+	}
+	return s
+}
+
+func TestMedianStdDev(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         []float64
+		median, stddev float64
+	}{
+		{"пустой ряд", nil, 0, 0},
+		{"одно значение", []float64{90}, 90, 0},
+		{"нечётное количество", []float64{3, 1, 2}, 2, math.Sqrt(2.0 / 3)},
+		{"чётное количество", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 4.5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := seriesStats(tt.values...)
+			if got := s.Median(); got != tt.median {
+				t.Errorf("Median() = %v, want %v", got, tt.median)
+			}
+			if got := s.StdDev(); math.Abs(got-tt.stddev) > 1e-12 {
+				t.Errorf("StdDev() = %v, want %v", got, tt.stddev)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	store := NewStatsStore()
+	day := newDay(testDate(time.March, 1), map[string]string{"USD": "90", "EUR": "100"})
+	day.Valutes = append(day.Valutes, Valute{ID: "R01820", NumCode: "392", CharCode: "JPY", Nominal: 100, Name: "Японских иен", Value: "60"})
+	store.Update(day)
+
+	tests := []struct {
+		amount   float64
+		from, to string
+		want     float64
+	}{
+		{100, "USD", "EUR", 90},
+		{100, "USD", "RUB", 9000},
+		{100, "rub", "usd", 100.0 / 90},
+		{1000, "JPY", "RUB", 600}, // Курс указан за 100 иен
+		{3, "USD", "JPY", 450},
+	}
+	for _, tt := range tests {
+		got, err := store.Convert(tt.amount, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("Convert(%v, %s, %s): %v", tt.amount, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Convert(%v, %s, %s) = %v, want %v", tt.amount, tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	if _, err := store.Convert(1, "USD", "XYZ"); err == nil {
+		t.Error("Convert(USD, XYZ): ожидалась ошибка для неизвестной валюты")
+	}
+}
+
+func TestStatsStoreDeduplicatesDate(t *testing.T) {
+	// На выходные ЦБ РФ возвращает курсы пятницы с той же датой в атрибуте Date
+	store := NewStatsStore()
+	friday := newDay(testDate(time.March, 1), map[string]string{"USD": "90", "EUR": "98"})
+	for i := 0; i < 3; i++ {
+		store.Update(friday)
+	}
+	store.Update(newDay(testDate(time.March, 4), map[string]string{"USD": "92", "EUR": "99"}))
+
+	stats := store.Snapshot()
+	for code, want := range map[string]float64{"USD": 91, "EUR": 98.5} {
+		if s := stats[code]; s.Count != 2 || s.Average != want || s.DaysInRange != 2 {
+			t.Errorf("%s: Count = %d, Average = %v, DaysInRange = %d, want 2, %v, 2", code, s.Count, s.Average, s.DaysInRange, want)
+		}
+	}
+}
+
+func TestStatsStoreSkipsInvalidValutes(t *testing.T) {
+	day := newDay(testDate(time.March, 1), map[string]string{"USD": "90"})
+	day.Valutes = append(day.Valutes,
+		Valute{ID: "R1", NumCode: "978", CharCode: "", Nominal: 1, Value: "98"},
+		Valute{ID: "R2", NumCode: "", CharCode: "GBP", Nominal: 1, Value: "115"},
+		Valute{ID: "R3", NumCode: "392", CharCode: "JPY", Nominal: 0, Value: "60"},
+	)
+	store := NewStatsStore()
+	store.Update(day)
+
+	stats := store.Snapshot()
+	if len(stats) != 1 || stats["USD"].Count != 1 {
+		t.Errorf("stats = %v, want только USD", SortedStats(stats))
+	}
+	if _, ok := stats[""]; ok {
+		t.Error("валюта с пустым кодом учтена в статистике")
+	}
+}
+
+func TestTrend(t *testing.T) {
+	tests := []struct {
+		name                string
+		values              []float64
+		first, last, change float64
+		percent             float64
+		trend               Trend
+	}{
+		{"рост", []float64{80, 85, 83, 100}, 80, 100, 20, 25, TrendUp},
+		{"снижение", []float64{100, 95, 97, 90}, 100, 90, -10, -10, TrendDown},
+		{"в пределах порога", []float64{100, 110, 100.05}, 100, 100.05, 0.05, 0.05, TrendFlat},
+		{"пустой ряд", nil, 0, 0, 0, 0, TrendFlat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := seriesStats(tt.values...)
+			if s.First() != tt.first || s.Last() != tt.last {
+				t.Errorf("First() = %v, Last() = %v, want %v и %v", s.First(), s.Last(), tt.first, tt.last)
+			}
+			if math.Abs(s.Change()-tt.change) > 1e-9 || math.Abs(s.ChangePercent()-tt.percent) > 1e-9 {
+				t.Errorf("Change() = %v, ChangePercent() = %v, want %v и %v", s.Change(), s.ChangePercent(), tt.change, tt.percent)
+			}
+			if got := s.Trend(); got != tt.trend {
+				t.Errorf("Trend() = %q, want %q", got, tt.trend)
+			}
+		})
+	}
+}
+
+func TestStatsStoreSkipsEmptyValues(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	defer slog.SetDefault(previous)
+
+	tests := []struct {
+		value string
+		warn  bool // Ожидается предупреждение в журнале
+	}{
+		{"", false},
+		{"   ", false},
+		{"abc", true},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		store := NewStatsStore()
+		store.Update(newDay(testDate(time.March, 1), map[string]string{"USD": "90", "EUR": tt.value}))
+
+		stats := store.Snapshot()
+		if _, ok := stats["EUR"]; ok || stats["USD"].Count != 1 {
+			t.Errorf("Value %q: stats = %v, want только USD", tt.value, SortedStats(stats))
+		}
+		if warned := strings.Contains(buf.String(), "level=WARN"); warned != tt.warn {
+			t.Errorf("Value %q: предупреждение = %v, want %v:\n%s", tt.value, warned, tt.warn, buf.String())
+		}
+	}
+}
+
+func TestStatsStoreWarnsNumCodeMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	defer slog.SetDefault(previous)
+
+	tests := []struct {
+		charCode, numCode string
+		warn              bool // Ожидается предупреждение в журнале
+	}{
+		{"usd", "840", false},
+		{"JPY", "392", false},
+		{"USD", "999", true},
+		{"XYZ", "123", false}, // Коды вне справочника журналируются только на уровне debug
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		day := newDay(testDate(time.March, 1), nil)
+		day.Valutes = []Valute{{ID: "R01", NumCode: tt.numCode, CharCode: tt.charCode, Nominal: 1, Name: tt.charCode, Value: "90"}}
+		store := NewStatsStore()
+		store.Update(day)
+
+		if stats := store.Snapshot(); stats[strings.ToUpper(tt.charCode)].Count != 1 {
+			t.Errorf("%s/%s: stats = %v, want курс сохранён несмотря на проверку кодов", tt.charCode, tt.numCode, SortedStats(stats))
+		}
+		log := buf.String()
+		if warned := strings.Contains(log, "level=WARN"); warned != tt.warn {
+			t.Errorf("%s/%s: предупреждение = %v, want %v:\n%s", tt.charCode, tt.numCode, warned, tt.warn, log)
+		}
+		if tt.warn && !strings.Contains(log, "expected=840") {
+			t.Errorf("Предупреждение не содержит ожидаемый код 840:\n%s", log)
+		}
+	}
+}
+
+func TestStatsStoreTieBreak(t *testing.T) {
+	// Максимум 92 и минимум 90 повторяются; при любом порядке дней максимум относится
+	// к самой поздней дате, а минимум — к самой ранней
+	days := []ValCurs{
+		newDay(testDate(time.March, 1), map[string]string{"USD": "90"}),
+		newDay(testDate(time.March, 2), map[string]string{"USD": "92"}),
+		newDay(testDate(time.March, 3), map[string]string{"USD": "90"}),
+		newDay(testDate(time.March, 4), map[string]string{"USD": "92"}),
+		newDay(testDate(time.March, 5), map[string]string{"USD": "91"}),
+	}
+	for _, order := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {2, 3, 0, 4, 1}} {
+		store := NewStatsStore()
+		for _, i := range order {
+			store.Update(days[i])
+		}
+		usd := store.Snapshot()["USD"]
+		if usd.MaxDate != "04.03.2024" || usd.MinDate != "01.03.2024" {
+			t.Errorf("порядок %v: MaxDate = %s, MinDate = %s, want 04.03.2024 и 01.03.2024", order, usd.MaxDate, usd.MinDate)
+		}
+	}
+}
+
+func TestPerUnitValues(t *testing.T) {
+	store := NewStatsStore()
+	for i, value := range []string{"60,5", "61,5", "62,5"} {
+		store.Update(ValCurs{Date: testDate(time.March, 1+i).Format(valCursDateLayout), Time: testDate(time.March, 1+i), Valutes: []Valute{
+			{ID: "R01820", NumCode: "392", CharCode: "JPY", Nominal: 100, Name: "Японских иен", Value: value},
+		}})
+	}
+
+	jpy := store.Snapshot()["JPY"]
+	if jpy.Average != 61.5 || jpy.Nominal != 100 {
+		t.Fatalf("Average = %v, Nominal = %d, want 61.5 и 100", jpy.Average, jpy.Nominal)
+	}
+	if jpy.UnitAverage() != 0.615 || jpy.UnitMax() != 0.625 || jpy.UnitMin() != 0.605 {
+		t.Errorf("UnitAverage = %v, UnitMax = %v, UnitMin = %v, want 0.615, 0.625 и 0.605", jpy.UnitAverage(), jpy.UnitMax(), jpy.UnitMin())
+	}
+
+	unit := jpy.ToUnit()
+	if !unit.PerUnit || unit.Nominal != 1 || unit.Average != 0.615 || unit.MaxValue != 0.625 || unit.Series[0].Value != 0.605 {
+		t.Errorf("ToUnit() = %+v", unit)
+	}
+	// Повторный пересчёт не меняет значения
+	if again := unit.ToUnit(); again.Average != unit.Average {
+		t.Errorf("ToUnit().ToUnit() Average = %v, want %v", again.Average, unit.Average)
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	s := seriesStats(1, 2, 3, 4, 5, 6, 7)
+
+	got := s.MovingAverage(3)
+	want := []float64{2, 3, 4, 5, 6}
+	if len(got) != len(want) {
+		t.Fatalf("len(MovingAverage(3)) = %d, want %d", len(got), len(want))
+	}
+	for i, p := range got {
+		if math.Abs(p.Value-want[i]) > 1e-12 {
+			t.Errorf("MovingAverage(3)[%d] = %v, want %v", i, p.Value, want[i])
+		}
+		// Точка соответствует последней дате окна
+		if !p.Date.Equal(testDate(time.January, 3+i)) {
+			t.Errorf("MovingAverage(3)[%d].Date = %v, want %v", i, p.Date, testDate(time.January, 3+i))
+		}
+	}
+
+	if got := s.MovingAverage(7); len(got) != 1 || got[0].Value != 4 {
+		t.Errorf("MovingAverage(7) = %v, want одно значение 4", got)
+	}
+	for _, window := range []int{0, -1, 8} {
+		if got := s.MovingAverage(window); got != nil {
+			t.Errorf("MovingAverage(%d) = %v, want nil", window, got)
+		}
+	}
+}
+
+func TestOutliers(t *testing.T) {
+	s := seriesStats(90, 91, 910, 92, 40, 93)
+
+	got := s.Outliers(50)
+	// Возврат к 92 после скачка не считается выбросом, падение до 40 — считается
+	if len(got) != 2 || got[0].Value != 910 || got[1].Value != 40 {
+		t.Errorf("Outliers(50) = %v, want 910 и 40", got)
+	}
+	if got := s.Outliers(2000); len(got) != 0 {
+		t.Errorf("Outliers(2000) = %v, want пусто", got)
+	}
+}
+
+func TestGroupByInterval(t *testing.T) {
+	// Курсы с понедельника 26.02.2024 по воскресенье 17.03.2024: значение курса равно дню периода
+	store := NewStatsStore()
+	start := testDate(time.February, 26)
+	for i := 0; i < 21; i++ {
+		store.Update(newDay(start.AddDate(0, 0, i), map[string]string{"USD": strconv.Itoa(i + 1)}))
+	}
+
+	tests := []struct {
+		interval Interval
+		want     []string // Границы интервалов в формате ГГГГ-ММ-ДД..ГГГГ-ММ-ДД
+		counts   []int
+		minDates []string
+	}{
+		{
+			interval: IntervalWeek,
+			want:     []string{"2024-02-26..2024-03-03", "2024-03-04..2024-03-10", "2024-03-11..2024-03-17"},
+			counts:   []int{7, 7, 7},
+			minDates: []string{"26.02.2024", "04.03.2024", "11.03.2024"},
+		},
+		{
+			interval: IntervalMonth,
+			want:     []string{"2024-02-01..2024-02-29", "2024-03-01..2024-03-31"},
+			counts:   []int{4, 17},
+			minDates: []string{"26.02.2024", "01.03.2024"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.interval), func(t *testing.T) {
+			groups := GroupByInterval(store.Snapshot(), tt.interval)
+			if len(groups) != len(tt.want) {
+				t.Fatalf("len(groups) = %d, want %d", len(groups), len(tt.want))
+			}
+			for i, g := range groups {
+				if got := g.Start.Format(isoDateLayout) + ".." + g.End.Format(isoDateLayout); got != tt.want[i] {
+					t.Errorf("groups[%d] = %s, want %s", i, got, tt.want[i])
+				}
+				usd := g.Stats["USD"]
+				if usd.Count != tt.counts[i] || usd.DaysInRange != tt.counts[i] {
+					t.Errorf("groups[%d]: Count = %d, DaysInRange = %d, want %d", i, usd.Count, usd.DaysInRange, tt.counts[i])
+				}
+				if usd.MinDate != tt.minDates[i] {
+					t.Errorf("groups[%d]: MinDate = %s, want %s", i, usd.MinDate, tt.minDates[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	for _, name := range []string{"day", "Week", "MONTH"} {
+		if _, err := ParseInterval(name); err != nil {
+			t.Errorf("ParseInterval(%q): %v", name, err)
+		}
+	}
+	if _, err := ParseInterval("year"); err == nil {
+		t.Error("ParseInterval(\"year\"): ожидалась ошибка")
+	}
+}
+
+func TestLatestValue(t *testing.T) {
+	store := NewStatsStore()
+	// Дни учитываются не по порядку, как при параллельной загрузке
+	store.Update(newDay(testDate(time.March, 5), map[string]string{"USD": "91,5", "EUR": "99"}))
+	store.Update(newDay(testDate(time.March, 7), map[string]string{"USD": "92,25"}))
+	store.Update(newDay(testDate(time.March, 4), map[string]string{"USD": "90", "EUR": "98"}))
+
+	tests := []struct {
+		code  string
+		value float64
+		date  string
+	}{
+		{"USD", 92.25, "07.03.2024"},
+		{"eur", 99, "05.03.2024"}, // Последний курс EUR учтён раньше последнего курса USD
+	}
+	for _, tt := range tests {
+		value, date, err := store.LatestValue(tt.code)
+		if err != nil {
+			t.Errorf("LatestValue(%s): %v", tt.code, err)
+			continue
+		}
+		if value != tt.value || date != tt.date {
+			t.Errorf("LatestValue(%s) = %v, %s, want %v, %s", tt.code, value, date, tt.value, tt.date)
+		}
+	}
+
+	if _, _, err := store.LatestValue("JPY"); err == nil {
+		t.Error("LatestValue(JPY): ожидалась ошибка для валюты без курсов")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	// Курс не меняется три дня, затем меняется дважды и возвращается к прежнему значению
+	s := seriesStats(90, 90, 90, 91, 91, 92, 92, 91)
+	want := []ChangePoint{
+		{Date: testDate(time.January, 4), OldValue: 90, NewValue: 91},
+		{Date: testDate(time.January, 6), OldValue: 91, NewValue: 92},
+		{Date: testDate(time.January, 8), OldValue: 92, NewValue: 91},
+	}
+	got := s.Changes()
+	if len(got) != len(want) {
+		t.Fatalf("Changes() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Date.Equal(want[i].Date) || got[i].OldValue != want[i].OldValue || got[i].NewValue != want[i].NewValue {
+			t.Errorf("Changes()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got := seriesStats(90, 90, 90).Changes(); len(got) != 0 {
+		t.Errorf("Changes() неизменного ряда = %v, want пусто", got)
+	}
+}
@@ -0,0 +1,49 @@
+package exchangerates
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+)
+
+// valuteJSON описывает курс одной валюты за день для потокового вывода
+type valuteJSON struct {
+	Code    string  `json:"code"`    // Символьный код валюты
+	Nominal int     `json:"nominal"` // Номинал
+	Value   float64 `json:"value"`   // Курс за номинал
+}
+
+// dayJSON описывает курсы валют за один день для потокового вывода в формате JSON Lines
+type dayJSON struct {
+	Date    string       `json:"date"`                // Дата курсов в формате ГГГГ-ММ-ДД
+	Request string       `json:"requested,omitempty"` // Запрошенная дата в формате ГГГГ-ММ-ДД
+	Matches bool         `json:"matches"`             // Совпадает ли дата курсов с запрошенной
+	Source  string       `json:"source,omitempty"`    // Откуда получены курсы (см. ValCurs.Origin)
+	Valutes []valuteJSON `json:"valutes"`             // Курсы валют
+}
+
+// writeDay выводит курсы валют за день одной строкой JSON. Учитываются только валюты
+// из currencies, если список не пуст; значения, которые не удалось разобрать, пропускаются.
+func writeDay(w io.Writer, valCurs ValCurs, currencies []string) error {
+	day := dayJSON{
+		Date:    valCurs.Time.Format(isoDateLayout),
+		Matches: valCurs.MatchesRequest(),
+		Source:  valCurs.Origin,
+		Valutes: []valuteJSON{},
+	}
+	if !valCurs.Request.IsZero() {
+		day.Request = valCurs.Request.Format(isoDateLayout)
+	}
+	for _, valute := range valCurs.Valutes {
+		if len(currencies) > 0 && !slices.Contains(currencies, strings.ToUpper(valute.CharCode)) {
+			continue
+		}
+		value, err := valute.FloatValue()
+		if err != nil {
+			continue
+		}
+		day.Valutes = append(day.Valutes, valuteJSON{Code: valute.CharCode, Nominal: valute.Nominal, Value: value})
+	}
+	return json.NewEncoder(w).Encode(day)
+}
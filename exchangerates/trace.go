@@ -0,0 +1,75 @@
+package exchangerates
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// EnableTracing включает журналирование этапов каждого запроса клиента client
+// (DNS, установка соединения, TLS, время до первого байта ответа) на уровне debug
+func EnableTracing(client *http.Client) {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &tracingTransport{base: base}
+}
+
+// tracingTransport добавляет к запросам httptrace.ClientTrace и журналирует замеры времени
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+// requestTimings содержит замеры этапов одного запроса. Обработчики httptrace
+// могут вызываться из разных горутин, поэтому доступ защищён мьютексом.
+type requestTimings struct {
+	mu                               sync.Mutex
+	dnsStart, connectStart, tlsStart time.Time
+	dns, connect, tls, firstByte     time.Duration
+	reused                           bool
+}
+
+// clientTrace возвращает обработчики httptrace, заполняющие замеры относительно start
+func (t *requestTimings) clientTrace(start time.Time) *httptrace.ClientTrace {
+	record := func(f func()) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		f()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { record(func() { t.dnsStart = time.Now() }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { record(func() { t.dns = time.Since(t.dnsStart) }) },
+		ConnectStart: func(string, string) {
+			record(func() { t.connectStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			record(func() { t.connect = time.Since(t.connectStart) })
+		},
+		TLSHandshakeStart: func() { record(func() { t.tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func() { t.tls = time.Since(t.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) { record(func() { t.reused = info.Reused }) },
+		GotFirstResponseByte: func() {
+			record(func() { t.firstByte = time.Since(start) })
+		},
+	}
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	timings := &requestTimings{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timings.clientTrace(start)))
+
+	resp, err := t.base.RoundTrip(req)
+
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+	slog.Debug("Замеры запроса к API", "url", req.URL.String(), "dns", timings.dns, "connect", timings.connect,
+		"tls", timings.tls, "ttfb", timings.firstByte, "total", time.Since(start), "reused", timings.reused, "error", err)
+	return resp, err
+}
@@ -0,0 +1,84 @@
+package exchangerates
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientTraceHooks(t *testing.T) {
+	trace := (&requestTimings{}).clientTrace(time.Now())
+	hooks := map[string]bool{
+		"DNSStart":             trace.DNSStart != nil,
+		"DNSDone":              trace.DNSDone != nil,
+		"ConnectStart":         trace.ConnectStart != nil,
+		"ConnectDone":          trace.ConnectDone != nil,
+		"TLSHandshakeStart":    trace.TLSHandshakeStart != nil,
+		"TLSHandshakeDone":     trace.TLSHandshakeDone != nil,
+		"GotConn":              trace.GotConn != nil,
+		"GotFirstResponseByte": trace.GotFirstResponseByte != nil,
+	}
+	for name, set := range hooks {
+		if !set {
+			t.Errorf("обработчик %s не задан", name)
+		}
+	}
+}
+
+func TestEnableTracing(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(previous)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := server.Client()
+	EnableTracing(client)
+	for i := 0; i < 2; i++ {
+		if _, err := FetchCurrencyRates(context.Background(), client, server.URL, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Длительности записываются в журнал JSON в наносекундах
+	type timings struct {
+		Msg     string `json:"msg"`
+		Connect int64  `json:"connect"`
+		TLS     int64  `json:"tls"`
+		TTFB    int64  `json:"ttfb"`
+		Total   int64  `json:"total"`
+		Reused  bool   `json:"reused"`
+	}
+	var records []timings
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r timings
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("строка журнала %q: %v", line, err)
+		}
+		if r.Msg == "Замеры запроса к API" {
+			records = append(records, r)
+		}
+	}
+	if len(records) != 2 {
+		t.Fatalf("замеров = %d, want 2:\n%s", len(records), buf.String())
+	}
+
+	first, second := records[0], records[1]
+	if first.Reused || first.Connect <= 0 || first.TLS <= 0 || first.TTFB <= 0 || first.Total < first.TTFB {
+		t.Errorf("первый запрос: %+v, want новое соединение с замерами connect, tls и ttfb", first)
+	}
+	// Повторный запрос использует открытое соединение без установки и TLS
+	if !second.Reused || second.Connect != 0 || second.TLS != 0 || second.TTFB <= 0 {
+		t.Errorf("второй запрос: %+v, want повторно использованное соединение", second)
+	}
+}
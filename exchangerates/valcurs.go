@@ -0,0 +1,385 @@
+// Package exchangerates загружает курсы валют ЦБ РФ, разбирает XML-ответы
+// и накапливает статистику по курсам за период.
+package exchangerates
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// ValCurs представляет корневой элемент XML от ЦБ РФ с информацией о курсах валют
+type ValCurs struct {
+	XMLName xml.Name  `xml:"ValCurs"`
+	Date    string    `xml:"Date,attr"` // Дата курса валют в формате дд.мм.гггг
+	Time    time.Time `xml:"-"`         // Дата курса валют, разобранная из атрибута Date
+	Request time.Time `xml:"-"`         // Запрошенная дата (параметр date_req); нулевая, если курсы не запрашивались по дате
+	Valutes []Valute  `xml:"Valute"`    // Список валют
+	Origin  string    `xml:"-"`         // Откуда получены курсы: OriginNetwork, OriginCache, OriginFile или OriginMemory
+	Size    int       `xml:"-"`         // Размер исходного ответа в байтах
+	Base    string    `xml:"-"`         // Валюта, в которой выражены курсы; пустая строка означает рубль (BaseRUB)
+}
+
+// Валюты, относительно которых публикуют курсы источники (ValCurs.Base)
+const (
+	BaseRUB = "RUB" // ЦБ РФ
+	BaseEUR = "EUR" // ЕЦБ
+)
+
+// BaseCurrency возвращает символьный код валюты, в которой выражены курсы
+func (v ValCurs) BaseCurrency() string {
+	if v.Base == "" {
+		return BaseRUB
+	}
+	return v.Base
+}
+
+// MatchesRequest сообщает, совпадает ли дата курсов с запрошенной. ЦБ РФ на дату,
+// в которую курсы не устанавливались, возвращает курсы предыдущего рабочего дня.
+// Если дата не запрашивалась (например, при чтении из файла), возвращается true.
+func (v ValCurs) MatchesRequest() bool {
+	return v.Request.IsZero() || v.Time.Equal(v.Request)
+}
+
+// Возможные источники полученных курсов (ValCurs.Origin)
+const (
+	OriginNetwork = "network"    // Ответ API
+	OriginCache   = "cache"      // Файловый кэш ответов API
+	OriginFile    = "input-file" // Сохранённый файл с ответом, указанный пользователем
+	OriginMemory  = "memory"     // Кэш разобранных курсов в памяти (см. ParsedCache)
+)
+
+// Valute содержит информацию о конкретной валюте
+type Valute struct {
+	ID       string `xml:"ID,attr"`  // ID валюты
+	NumCode  string `xml:"NumCode"`  // Цифровой код валюты
+	CharCode string `xml:"CharCode"` // Символьный код валюты
+	Nominal  int    `xml:"Nominal"`  // Номинал валюты
+	Name     string `xml:"Name"`     // Название валюты
+	Value    string `xml:"Value"`    // Значение курса валюты
+}
+
+// ValidationError перечисляет нарушения структуры ответа ЦБ РФ, найденные ValCurs.Validate
+type ValidationError struct {
+	Problems []string // Описания нарушений
+}
+
+func (e *ValidationError) Error() string {
+	return "Некорректные данные в ответе: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate проверяет, что в ответе указана дата, а у каждой валюты заполнены ID,
+// символьный и цифровой коды, положительный номинал и корректное значение курса.
+// Все найденные нарушения возвращаются одной ошибкой *ValidationError.
+func (v ValCurs) Validate() error {
+	var problems []string
+	if strings.TrimSpace(v.Date) == "" {
+		problems = append(problems, "не указана дата курсов")
+	} else if _, err := time.Parse(valCursDateLayout, v.Date); err != nil {
+		problems = append(problems, fmt.Sprintf("некорректная дата курсов %q", v.Date))
+	}
+
+	for i, valute := range v.Valutes {
+		name := fmt.Sprintf("валюта %d (%s)", i+1, valute.CharCode)
+		if strings.TrimSpace(valute.ID) == "" {
+			problems = append(problems, name+": не указан ID")
+		}
+		if strings.TrimSpace(valute.CharCode) == "" {
+			problems = append(problems, name+": не указан символьный код")
+		}
+		if strings.TrimSpace(valute.NumCode) == "" {
+			problems = append(problems, name+": не указан цифровой код")
+		}
+		if valute.Nominal <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: некорректный номинал %d", name, valute.Nominal))
+		}
+		if _, err := valute.FloatValue(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: некорректное значение курса %q", name, valute.Value))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ErrEmptyValue возвращается для валюты с пустым значением курса,
+// которое встречается в некоторых исторических ответах ЦБ РФ
+var ErrEmptyValue = errors.New("пустое значение курса")
+
+// FloatValue возвращает значение курса валюты в виде числа.
+// ЦБ РФ использует запятую в качестве десятичного разделителя; точка также допускается (см. parseDecimal).
+// Для пустого значения возвращается ErrEmptyValue.
+func (v Valute) FloatValue() (float64, error) {
+	valueStr := strings.TrimSpace(v.Value)
+	if valueStr == "" {
+		return 0, fmt.Errorf("Валюта %s: %w", v.CharCode, ErrEmptyValue)
+	}
+	value, err := parseDecimal(valueStr)
+	if err != nil {
+		return 0, fmt.Errorf("Ошибка при преобразовании курса валюты %s: %w", v.CharCode, err)
+	}
+	return value, nil
+}
+
+// ratValue возвращает точное значение курса валюты в виде рационального числа
+// для расчёта среднего без ошибок округления (см. StatsStore.SetExact)
+func (v Valute) ratValue() (*big.Rat, error) {
+	valueStr := strings.TrimSpace(v.Value)
+	if valueStr == "" {
+		return nil, fmt.Errorf("Валюта %s: %w", v.CharCode, ErrEmptyValue)
+	}
+	s, err := normalizeDecimal(valueStr)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при преобразовании курса валюты %s: %w", v.CharCode, err)
+	}
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("Ошибка при преобразовании курса валюты %s: некорректное число %q", v.CharCode, s)
+	}
+	return value, nil
+}
+
+// parseDecimal разбирает десятичное число, допуская пробелы между разрядами
+// и запятую или точку в качестве десятичного разделителя (см. normalizeDecimal)
+func parseDecimal(s string) (float64, error) {
+	s, err := normalizeDecimal(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// normalizeDecimal удаляет пробелы между разрядами и заменяет десятичную запятую точкой.
+// Числа с несколькими разделителями отклоняются, чтобы не спутать разделитель разрядов с десятичным.
+func normalizeDecimal(s string) (string, error) {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+
+	if strings.Count(s, ",")+strings.Count(s, ".") > 1 {
+		return "", fmt.Errorf("Несколько десятичных разделителей в значении %q", s)
+	}
+	return strings.Replace(s, ",", ".", 1), nil
+}
+
+// baseCurrencyNames задаёт названия валют, относительно которых публикуют курсы источники
+var baseCurrencyNames = map[string]string{
+	BaseRUB: "Russian Ruble",
+	BaseEUR: "Euro",
+}
+
+// Rebase пересчитывает курсы за день относительно валюты base по её курсу за тот же день.
+// Номиналы валют сохраняются, курс самой валюты base становится равным её номиналу.
+// В результат добавляется исходная базовая валюта (см. ValCurs.BaseCurrency),
+// а пересчитанные курсы получают базовую валюту base.
+func Rebase(valCurs ValCurs, base string) (ValCurs, error) {
+	base = strings.ToUpper(base)
+	from := valCurs.BaseCurrency()
+	if base == "" || base == from {
+		return valCurs, nil
+	}
+
+	var basePerUnit float64
+	for _, valute := range valCurs.Valutes {
+		if valute.CharCode != base {
+			continue
+		}
+		value, err := valute.FloatValue()
+		if err != nil {
+			return ValCurs{}, err
+		}
+		if value <= 0 || valute.Nominal <= 0 {
+			return ValCurs{}, fmt.Errorf("Некорректный курс базовой валюты %s за %s", base, valCurs.Date)
+		}
+		basePerUnit = value / float64(valute.Nominal)
+		break
+	}
+	if basePerUnit == 0 {
+		return ValCurs{}, fmt.Errorf("Нет курса базовой валюты %s за %s", base, valCurs.Date)
+	}
+
+	rebased := valCurs
+	rebased.Valutes = nil
+	rebased.Base = base
+	for _, valute := range valCurs.Valutes {
+		value, err := valute.FloatValue()
+		if err != nil {
+			logSkippedValue(valCurs.Date, err)
+			continue
+		}
+		valute.Value = strconv.FormatFloat(value/basePerUnit, 'f', -1, 64)
+		rebased.Valutes = append(rebased.Valutes, valute)
+	}
+	name, ok := baseCurrencyNames[from]
+	if !ok {
+		name = from
+	}
+	rebased.Valutes = append(rebased.Valutes, Valute{
+		ID:       from,
+		NumCode:  currencyNumCodes[from],
+		CharCode: from,
+		Nominal:  1,
+		Name:     name,
+		Value:    strconv.FormatFloat(1/basePerUnit, 'f', -1, 64),
+	})
+	return rebased, nil
+}
+
+// logSkippedValue журналирует пропуск значения курса. Пустые значения ожидаемы
+// в исторических ответах и журналируются только на уровне debug.
+func logSkippedValue(date string, err error) {
+	if errors.Is(err, ErrEmptyValue) {
+		slog.Debug("Пропуск пустого значения курса", "date", date, "error", err)
+		return
+	}
+	slog.Warn("Пропуск значения курса", "date", date, "error", err)
+}
+
+// valCursDateLayout задаёт формат атрибута Date в ответе ЦБ РФ (дд.мм.гггг)
+const valCursDateLayout = "02.01.2006"
+
+// isoDateLayout задаёт формат дат ГГГГ-ММ-ДД для хранения в базе данных и имён файлов кэша
+const isoDateLayout = "2006-01-02"
+
+// parseContextSize задаёт количество байт до и после места ошибки в сообщении о разборе XML
+const parseContextSize = 40
+
+// ParseError описывает ошибку структуры XML с указанием места в ответе
+type ParseError struct {
+	Offset  int64  // Смещение в байтах исходного ответа, на котором остановился разбор
+	Context string // Фрагмент ответа вокруг места ошибки в UTF-8
+	Err     error  // Исходная ошибка декодера
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Ошибка структуры XML на смещении %d (рядом с %q): %v", e.Offset, e.Context, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// errorContext возвращает фрагмент данных вокруг смещения offset
+func errorContext(data string, offset int64) string {
+	start := int(offset) - parseContextSize
+	if start < 0 {
+		start = 0
+	}
+	end := int(offset) + parseContextSize
+	if end > len(data) {
+		end = len(data)
+	}
+	if start > end {
+		start = end
+	}
+	return data[start:end]
+}
+
+// defaultCharset задаёт кодировку ответов ЦБ РФ, которая предполагается,
+// если в объявлении XML кодировка не указана
+const defaultCharset = "windows-1251"
+
+// xmlEncodingRe находит кодировку в объявлении XML
+var xmlEncodingRe = regexp.MustCompile(`^\s*<\?xml[^>]*?encoding\s*=\s*["']([^"']+)["']`)
+
+// toUTF8 преобразует XML в UTF-8 согласно кодировке из объявления XML и возвращает
+// использованную кодировку; nil означает, что данные уже в UTF-8 и не преобразовывались.
+// Если кодировка не объявлена, данные декодируются из windows-1251 — кодировки ответов ЦБ РФ.
+// Если объявлена UTF-8, но данные ей не соответствуют, они также декодируются
+// из windows-1251 с предупреждением.
+func toUTF8(data string) (string, encoding.Encoding, error) {
+	label := defaultCharset
+	if m := xmlEncodingRe.FindStringSubmatch(data); m != nil {
+		label = strings.ToLower(strings.TrimSpace(m[1]))
+	}
+
+	if label == "utf-8" || label == "utf8" {
+		if utf8.ValidString(data) {
+			return data, nil, nil
+		}
+		slog.Warn("XML объявлен в UTF-8, но содержит некорректные символы, используется кодировка по умолчанию", "charset", defaultCharset)
+		label = defaultCharset
+	}
+
+	enc, name := charset.Lookup(label)
+	if enc == nil {
+		return "", nil, fmt.Errorf("Неподдерживаемая кодировка XML %q", label)
+	}
+	decoded, err := enc.NewDecoder().String(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("Ошибка при преобразовании XML из кодировки %s: %w", name, err)
+	}
+	return decoded, enc, nil
+}
+
+// sourceOffset переводит смещение в данных, преобразованных toUTF8 из кодировки enc,
+// в смещение в исходных данных
+func sourceOffset(decoded string, enc encoding.Encoding, offset int64) int64 {
+	if enc == nil || offset <= 0 {
+		return offset
+	}
+	if offset > int64(len(decoded)) {
+		offset = int64(len(decoded))
+	}
+	// Символы, которых нет в исходной кодировке (например, U+FFFD), заменяются одним байтом
+	encoded, err := encoding.ReplaceUnsupported(enc.NewEncoder()).String(decoded[:offset])
+	if err != nil {
+		return offset
+	}
+	return int64(len(encoded))
+}
+
+// newUTF8Decoder возвращает XML-декодер для данных, уже преобразованных toUTF8:
+// объявленная в XML кодировка больше не требует преобразования
+func newUTF8Decoder(data string) *xml.Decoder {
+	decoder := xml.NewDecoder(strings.NewReader(data))
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+// ParseXML анализирует XML и возвращает структуру ValCurs с данными о курсах валют.
+// Кодировка определяется по объявлению XML (см. toUTF8), по умолчанию windows-1251.
+// Атрибут Date должен иметь формат дд.мм.гггг, иначе возвращается ошибка.
+// Ошибки кодировки возвращаются отдельно от ошибок структуры XML (*ParseError).
+func ParseXML(data string) (ValCurs, error) {
+	data, enc, err := toUTF8(data)
+	if err != nil {
+		parseFailures.Inc()
+		return ValCurs{}, fmt.Errorf("Ошибка кодировки XML: %w", err)
+	}
+
+	var valCurs ValCurs
+	decoder := newUTF8Decoder(data)
+	if err := decoder.Decode(&valCurs); err != nil {
+		parseFailures.Inc()
+		offset := decoder.InputOffset()
+		return ValCurs{}, &ParseError{Offset: sourceOffset(data, enc, offset), Context: errorContext(data, offset), Err: err}
+	}
+
+	valCurs.Time, err = time.Parse(valCursDateLayout, valCurs.Date)
+	if err != nil {
+		parseFailures.Inc()
+		return ValCurs{}, fmt.Errorf("Некорректная дата курсов %q: %w", valCurs.Date, err)
+	}
+
+	return valCurs, nil
+}
@@ -0,0 +1,376 @@
+package exchangerates
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestParseXMLSample(t *testing.T) {
+	valCurs, err := ParseXML(readTestdata(t, "XML_daily_eng.xml"))
+	if err != nil {
+		t.Fatalf("ParseXML: %v", err)
+	}
+	if valCurs.Date != "02.02.2024" || !valCurs.Time.Equal(time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Date = %q, Time = %v", valCurs.Date, valCurs.Time)
+	}
+	if valCurs.BaseCurrency() != BaseRUB {
+		t.Errorf("BaseCurrency() = %q, want %q", valCurs.BaseCurrency(), BaseRUB)
+	}
+	if len(valCurs.Valutes) != 4 {
+		t.Fatalf("len(Valutes) = %d, want 4", len(valCurs.Valutes))
+	}
+
+	jpy := valCurs.Valutes[3]
+	if jpy.ID != "R01820" || jpy.CharCode != "JPY" || jpy.NumCode != "392" || jpy.Nominal != 100 || jpy.Name != "Japanese Yen" {
+		t.Errorf("JPY = %+v", jpy)
+	}
+	value, err := jpy.FloatValue()
+	if err != nil || value != 61.5419 {
+		t.Errorf("FloatValue() = %v, %v, want 61.5419", value, err)
+	}
+}
+
+func TestParseXMLFixture(t *testing.T) {
+	// Сохранённый ответ XML_daily.asp в кодировке windows-1251
+	valCurs, err := ParseXML(readTestdata(t, "XML_daily.xml"))
+	if err != nil {
+		t.Fatalf("ParseXML: %v", err)
+	}
+	if valCurs.Date != "02.02.2024" {
+		t.Errorf("Date = %q, want 02.02.2024", valCurs.Date)
+	}
+	if len(valCurs.Valutes) != 11 {
+		t.Fatalf("len(Valutes) = %d, want 11", len(valCurs.Valutes))
+	}
+
+	var usd *Valute
+	for i, v := range valCurs.Valutes {
+		if v.CharCode == "USD" {
+			usd = &valCurs.Valutes[i]
+		}
+	}
+	if usd == nil {
+		t.Fatal("нет курса USD")
+	}
+	if usd.Name != "Доллар США" {
+		t.Errorf("Name = %q, want Доллар США", usd.Name)
+	}
+	if err := valCurs.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+// encode1251 преобразует строку в кодировку windows-1251
+func encode1251(t *testing.T, s string) string {
+	t.Helper()
+	encoded, err := charmap.Windows1251.NewEncoder().String(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func TestParseXMLWithoutDeclaration(t *testing.T) {
+	data := encode1251(t, `<ValCurs Date="02.02.2024"><Valute ID="R01235"><NumCode>840</NumCode>`+
+		`<CharCode>USD</CharCode><Nominal>1</Nominal><Name>Доллар США</Name><Value>90,2826</Value></Valute></ValCurs>`)
+
+	valCurs, err := ParseXML(data)
+	if err != nil {
+		t.Fatalf("ParseXML: %v", err)
+	}
+	if len(valCurs.Valutes) != 1 || valCurs.Valutes[0].Name != "Доллар США" {
+		t.Errorf("Valutes = %+v, want Доллар США", valCurs.Valutes)
+	}
+}
+
+func TestParseXMLDeclaredUTF8(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?><ValCurs Date="02.02.2024"><Valute ID="R01235">` +
+		`<CharCode>USD</CharCode><Nominal>1</Nominal><Name>Доллар США</Name><Value>90,2826</Value></Valute></ValCurs>`
+
+	valCurs, err := ParseXML(data)
+	if err != nil {
+		t.Fatalf("ParseXML: %v", err)
+	}
+	if valCurs.Valutes[0].Name != "Доллар США" {
+		t.Errorf("Name = %q, want Доллар США", valCurs.Valutes[0].Name)
+	}
+
+	// Объявлена UTF-8, но данные в windows-1251
+	valCurs, err = ParseXML(encode1251(t, data))
+	if err != nil {
+		t.Fatalf("ParseXML: %v", err)
+	}
+	if valCurs.Valutes[0].Name != "Доллар США" {
+		t.Errorf("Name = %q, want Доллар США", valCurs.Valutes[0].Name)
+	}
+}
+
+func TestParseXMLUnsupportedCharset(t *testing.T) {
+	_, err := ParseXML(`<?xml version="1.0" encoding="x-unknown"?><ValCurs Date="02.02.2024"></ValCurs>`)
+	if err == nil || !strings.Contains(err.Error(), "x-unknown") {
+		t.Fatalf("err = %v, want unsupported charset", err)
+	}
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		t.Error("ошибка кодировки не должна быть ошибкой структуры XML")
+	}
+}
+
+func TestParseXMLOffsetInSourceBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		suffix string // Окончание исходных данных до места ошибки
+	}{
+		{
+			name:   "обрезанный ответ",
+			data:   encode1251(t, `<ValCurs Date="02.02.2024"><Valute ID="R01235"><Name>Доллар США</Name></Valute><Valute`),
+			suffix: "<Valute",
+		},
+		{
+			name:   "несовпадающий тег",
+			data:   encode1251(t, `<ValCurs Date="02.02.2024"><Valute ID="R01235"><Name>Доллар США</Nam></Valute></ValCurs>`),
+			suffix: "</Nam>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseXML(tt.data)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("err = %v, want *ParseError", err)
+			}
+			if parseErr.Offset > int64(len(tt.data)) || !strings.HasSuffix(tt.data[:parseErr.Offset], tt.suffix) {
+				t.Errorf("Offset = %d (len %d), want offset after %q", parseErr.Offset, len(tt.data), tt.suffix)
+			}
+			if !strings.Contains(parseErr.Context, "США") {
+				t.Errorf("Context = %q, want decoded text", parseErr.Context)
+			}
+		})
+	}
+}
+
+func TestParseXMLErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"обрезанный ответ", `<ValCurs Date="02.02.2024"><Valute ID="R01235"><CharCode>USD</CharCode>`},
+		{"некорректный XML", `<ValCurs Date="02.02.2024"><Valute ID="R01235"><CharCode>USD</Valute></ValCurs>`},
+		{"пустой ответ", ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseXML(tt.data)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("err = %v, want *ParseError", err)
+			}
+			if want := fmt.Sprintf("смещении %d", parseErr.Offset); !strings.Contains(err.Error(), want) {
+				t.Errorf("err = %q, want сообщение с %q", err, want)
+			}
+		})
+	}
+}
+
+func TestFloatValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "73,5000", want: 73.5},
+		{value: "1,0000", want: 1},
+		{value: "90.2826", want: 90.2826},
+		{value: " 1 234,5 ", want: 1234.5},
+		{value: "", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "1,234,5", wantErr: true},
+		{value: "1.234,5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Valute{CharCode: "USD", Value: tt.value}.FloatValue()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("FloatValue(%q): err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("FloatValue(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	if _, err := (Valute{Value: " "}).FloatValue(); !errors.Is(err, ErrEmptyValue) {
+		t.Errorf("FloatValue(\" \"): err = %v, want ErrEmptyValue", err)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	day := newDay(testDate(time.March, 1), map[string]string{"USD": "90", "EUR": "99"})
+	day.Valutes = append(day.Valutes, Valute{ID: "R01820", NumCode: "392", CharCode: "JPY", Nominal: 100, Name: "JPY", Value: "60"})
+
+	rebased, err := Rebase(day, "usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebased.BaseCurrency() != "USD" {
+		t.Errorf("BaseCurrency() = %q, want USD", rebased.BaseCurrency())
+	}
+	want := map[string]float64{"USD": 1, "EUR": 1.1, "JPY": 60.0 / 90, "RUB": 1.0 / 90}
+	if len(rebased.Valutes) != len(want) {
+		t.Fatalf("valutes = %+v, want %d", rebased.Valutes, len(want))
+	}
+	for _, v := range rebased.Valutes {
+		got, err := v.FloatValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-want[v.CharCode]) > 1e-12 {
+			t.Errorf("%s = %v, want %v", v.CharCode, got, want[v.CharCode])
+		}
+	}
+
+	if _, err := Rebase(day, "GBP"); err == nil {
+		t.Error("Rebase(GBP): ожидалась ошибка для дня без курса базовой валюты")
+	}
+}
+
+func TestRebaseUsesSameDayRates(t *testing.T) {
+	// Курсы пересчитываются по курсу базовой валюты за тот же день, а не по среднему за период
+	store := NewStatsStore()
+	for _, day := range []ValCurs{
+		newDay(testDate(time.March, 1), map[string]string{"USD": "90", "EUR": "99"}),
+		newDay(testDate(time.March, 2), map[string]string{"USD": "100", "EUR": "100"}),
+	} {
+		rebased, err := Rebase(day, "USD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.Update(rebased)
+	}
+
+	stats := store.Snapshot()
+	if got := stats["USD"]; got.MinValue != 1 || got.MaxValue != 1 {
+		t.Errorf("USD = %v — %v, want 1", got.MinValue, got.MaxValue)
+	}
+	if got := stats["EUR"].Average; math.Abs(got-1.05) > 1e-12 {
+		t.Errorf("EUR Average = %v, want 1.05", got)
+	}
+}
+
+func TestParseXMLDate(t *testing.T) {
+	tests := []struct {
+		date    string
+		want    time.Time
+		wantErr bool
+	}{
+		{date: "02.02.2024", want: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+		{date: "29.02.2024", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{date: "31.12.1999", want: time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{date: "", wantErr: true},
+		{date: "2024-02-02", wantErr: true},
+		{date: "02/02/2024", wantErr: true},
+		{date: "30.02.2024", wantErr: true},
+		{date: "2.2.2024", wantErr: true},
+	}
+	for _, tt := range tests {
+		valCurs, err := ParseXML(`<ValCurs Date="` + tt.date + `" name="Foreign Currency Market"></ValCurs>`)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Date %q: err = %v, wantErr %v", tt.date, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !valCurs.Time.Equal(tt.want) {
+			t.Errorf("Date %q: Time = %v, want %v", tt.date, valCurs.Time, tt.want)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "1 234,56", want: 1234.56},
+		{value: "1\u00a0234,56", want: 1234.56}, // Неразрывный пробел между разрядами
+		{value: "73.50", want: 73.5},
+		{value: "73,50", want: 73.5},
+		{value: "100", want: 100},
+		{value: "1,2,3", wantErr: true},
+		{value: "1.234,56", wantErr: true},
+		{value: "1,234.56", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDecimal(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDecimal(%q): err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDecimal(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+		if tt.wantErr {
+			continue
+		}
+		// Точное значение разбирается по тем же правилам
+		exact, err := Valute{Value: tt.value}.ratValue()
+		if f, _ := exact.Float64(); err != nil || f != tt.want {
+			t.Errorf("ratValue(%q) = %v, %v, want %v", tt.value, exact, err, tt.want)
+		}
+	}
+}
+
+func TestValCursValidate(t *testing.T) {
+	valid := func() ValCurs {
+		return ValCurs{Date: "02.02.2024", Valutes: []Valute{
+			{ID: "R01235", NumCode: "840", CharCode: "USD", Nominal: 1, Name: "US Dollar", Value: "90,2826"},
+			{ID: "R01820", NumCode: "392", CharCode: "JPY", Nominal: 100, Name: "Japanese Yen", Value: "61,0155"},
+		}}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Errorf("Validate() корректного ответа: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(*ValCurs)
+		problems []string // Фрагменты ожидаемых описаний нарушений
+	}{
+		{"без даты", func(v *ValCurs) { v.Date = " " }, []string{"не указана дата"}},
+		{"некорректная дата", func(v *ValCurs) { v.Date = "2024-02-02" }, []string{`некорректная дата курсов "2024-02-02"`}},
+		{"без ID", func(v *ValCurs) { v.Valutes[0].ID = "" }, []string{"валюта 1 (USD): не указан ID"}},
+		{"без кодов", func(v *ValCurs) { v.Valutes[1].CharCode, v.Valutes[1].NumCode = "", "" },
+			[]string{"валюта 2 (): не указан символьный код", "валюта 2 (): не указан цифровой код"}},
+		{"нулевой номинал", func(v *ValCurs) { v.Valutes[1].Nominal = 0 }, []string{"валюта 2 (JPY): некорректный номинал 0"}},
+		{"некорректный курс", func(v *ValCurs) { v.Valutes[0].Value = "n/a" }, []string{`валюта 1 (USD): некорректное значение курса "n/a"`}},
+		{"несколько нарушений", func(v *ValCurs) {
+			v.Date = ""
+			v.Valutes[0].Nominal = -1
+			v.Valutes[1].Value = ""
+		}, []string{"не указана дата", "валюта 1 (USD): некорректный номинал -1", `валюта 2 (JPY): некорректное значение курса ""`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := valid()
+			tt.modify(&v)
+			err := v.Validate()
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() = %v, want *ValidationError", err)
+			}
+			if len(validationErr.Problems) != len(tt.problems) {
+				t.Errorf("Problems = %q, want %d нарушений", validationErr.Problems, len(tt.problems))
+			}
+			for _, problem := range tt.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("Validate() = %q, want нарушение %q", err, problem)
+				}
+			}
+		})
+	}
+}
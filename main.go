@@ -1,159 +1,905 @@
 package main
 
 import (
-	"bytes"
-	"encoding/xml"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"golang.org/x/net/html/charset"
+	"github.com/Alfarabi09/Exchange_Rates/exchangerates"
+	"golang.org/x/time/rate"
 )
 
-// ValCurs представляет корневой элемент XML от ЦБ РФ с информацией о курсах валют
-type ValCurs struct {
-	XMLName xml.Name `xml:"ValCurs"`
-	Date    string   `xml:"Date,attr"` // Дата курса валют
-	Valutes []Valute `xml:"Valute"`    // Список валют
-}
-
-// Valute содержит информацию о конкретной валюте
-type Valute struct {
-	ID       string `xml:"ID,attr"`  // ID валюты
-	NumCode  string `xml:"NumCode"`  // Цифровой код валюты
-	CharCode string `xml:"CharCode"` // Символьный код валюты
-	Nominal  int    `xml:"Nominal"`  // Номинал валюты
-	Name     string `xml:"Name"`     // Название валюты
-	Value    string `xml:"Value"`    // Значение курса валюты
-}
-
-// CurrencyStats хранит статистику по курсам валюты
-type CurrencyStats struct {
-	MaxValue     float64 // Максимальное значение курса
-	MinValue     float64 // Минимальное значение курса
-	MaxDate      string  // Дата максимального курса
-	MinDate      string  // Дата минимального курса
-	TotalValue   float64 // Суммарное значение курса для расчета среднего
-	Count        int     // Количество записей для расчета среднего
-	Average      float64 // Среднее значение курса
-	Nominal      int     // Номинал валюты
-	CurrencyName string  // Название валюты
-	NumCode      string  // Цифровой код валюты
-	CharCode     string  // Символьный код валюты
-}
-
-var globalStats = make(map[string]*CurrencyStats) // Глобальный map для хранения статистики по валютам
-
-// fetchCurrencyRates выполняет запрос к API ЦБ РФ и возвращает XML с данными о курсах валют
-func fetchCurrencyRates(url string) (string, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+// flagDateLayout задаёт формат дат в параметрах командной строки
+const flagDateLayout = "2006-01-02"
+
+// defaultRateLimit задаёт максимальное количество запросов к API ЦБ РФ в секунду по умолчанию
+const defaultRateLimit = 5
+
+// defaultMAWindow задаёт окно скользящего среднего курса в днях по умолчанию
+const defaultMAWindow = 7
+
+// defaultRangeDays задаёт длину периода анализа по умолчанию в днях
+const defaultRangeDays = 90
+
+// parseCurrencyList разбирает список символьных кодов валют через запятую,
+// приводя коды к верхнему регистру и удаляя пустые значения и повторы
+func parseCurrencyList(list string) []string {
+	var codes []string
+	seen := make(map[string]bool)
+	for _, code := range strings.Split(list, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// readCurrenciesFile читает символьные коды валют из файла: по одному или несколько
+// через запятую на строку. Текст после # считается комментарием.
+// Коды нормализуются и избавляются от повторов так же, как в parseCurrencyList.
+func readCurrenciesFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return "", fmt.Errorf("Ошибка при создании запроса: %w", err)
+		return nil, fmt.Errorf("Ошибка при чтении файла валют: %w", err)
+	}
+
+	var codes []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		codes = append(codes, line)
+	}
+	return parseCurrencyList(strings.Join(codes, ",")), nil
+}
+
+// headerFlags собирает повторяющиеся флаги -header вида "Имя: значение"
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	*h = append(*h, value)
+	return nil
+}
+
+// parseHeaders разбирает заголовки вида "Имя: значение" и добавляет User-Agent
+func parseHeaders(userAgent string, headers []string) (http.Header, error) {
+	header := make(http.Header)
+	header.Set("User-Agent", userAgent)
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("Некорректный заголовок %q, ожидается \"Имя: значение\"", h)
+		}
+		header.Add(name, strings.TrimSpace(value))
 	}
+	return header, nil
+}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0")
+// parseProxy разбирает адрес прокси-сервера. Пустая строка означает прокси из переменных окружения.
+func parseProxy(addr string) (*url.URL, error) {
+	if addr == "" {
+		return nil, nil
+	}
 
-	resp, err := client.Do(req)
+	u, err := url.Parse(addr)
 	if err != nil {
-		return "", fmt.Errorf("Ошибка при запросе к API: %w", err)
+		return nil, fmt.Errorf("Некорректный адрес прокси %q: %w", addr, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("Неподдерживаемая схема прокси %q, ожидается http, https или socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("Не указан хост прокси в адресе %q", addr)
+	}
+	return u, nil
+}
+
+// parseConversion разбирает запрос на пересчёт вида "100 USD EUR"
+func parseConversion(query string) (float64, string, string, error) {
+	fields := strings.Fields(query)
+	if len(fields) != 3 {
+		return 0, "", "", fmt.Errorf("Некорректный запрос на пересчёт %q, ожидается \"<сумма> <из> <в>\"", query)
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	amount, err := strconv.ParseFloat(strings.Replace(fields[0], ",", ".", -1), 64)
 	if err != nil {
-		return "", fmt.Errorf("Ошибка при чтении ответа: %w", err)
+		return 0, "", "", fmt.Errorf("Некорректная сумма %q: %w", fields[0], err)
+	}
+	return amount, strings.ToUpper(fields[1]), strings.ToUpper(fields[2]), nil
+}
+
+// parseBasket разбирает состав корзины валют вида "USD:0.6,EUR:0.4"
+func parseBasket(value string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, item := range strings.Split(value, ",") {
+		code, weightStr, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || code == "" {
+			return nil, fmt.Errorf("Некорректная валюта корзины %q, ожидается \"<код>:<вес>\"", item)
+		}
+
+		weight, err := strconv.ParseFloat(strings.Replace(weightStr, ",", ".", -1), 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("Некорректный вес валюты %s: %q", code, weightStr)
+		}
+		code = strings.ToUpper(code)
+		if _, ok := weights[code]; ok {
+			return nil, fmt.Errorf("Валюта %s указана в корзине повторно", code)
+		}
+		weights[code] = weight
 	}
+	return weights, nil
+}
 
-	return string(body), nil
+// parseCompareRange разбирает период сравнения вида ГГГГ-ММ-ДД:ГГГГ-ММ-ДД
+func parseCompareRange(value string) (time.Time, time.Time, error) {
+	startStr, endStr, ok := strings.Cut(value, ":")
+	if !ok || startStr == "" || endStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("Некорректный период сравнения %q, ожидается \"ГГГГ-ММ-ДД:ГГГГ-ММ-ДД\"", value)
+	}
+	return parseDateRange(startStr, endStr, defaultRangeDays, time.Now())
 }
 
-// parseXML анализирует XML и возвращает структуру ValCurs с данными о курсах валют
-func parseXML(data string) (ValCurs, error) {
-	var valCurs ValCurs
-	reader := bytes.NewReader([]byte(data))
-	decoder := xml.NewDecoder(reader)
-	decoder.CharsetReader = charset.NewReaderLabel // Для обработки кодировки windows-1251
+// parseDateRange разбирает границы периода в формате ГГГГ-ММ-ДД.
+// Пустая граница заменяется значением по умолчанию: последние sinceDays дней, включая день now.
+func parseDateRange(startStr, endStr string, sinceDays int, now time.Time) (time.Time, time.Time, error) {
+	if sinceDays <= 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("Некорректное количество дней %d, ожидается положительное число", sinceDays)
+	}
 
-	err := decoder.Decode(&valCurs)
-	if err != nil {
-		return ValCurs{}, err
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start := today.AddDate(0, 0, -(sinceDays - 1)) // Сегодняшний день входит в период
+	end := today
+
+	var err error
+	if startStr != "" {
+		start, err = time.Parse(flagDateLayout, startStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Некорректная начальная дата %q: %w", startStr, err)
+		}
+	}
+	if endStr != "" {
+		end, err = time.Parse(flagDateLayout, endStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("Некорректная конечная дата %q: %w", endStr, err)
+		}
+	}
+
+	if start.After(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("Начальная дата %s позже конечной %s",
+			start.Format(flagDateLayout), end.Format(flagDateLayout))
 	}
+	return start, end, nil
+}
 
-	return valCurs, nil
+// parseLogLevel разбирает уровень журналирования (debug, info, warn или error).
+// При quiet уровень повышается до error: сообщения об ошибках отдельных дней
+// учитываются только в итоговой сводке.
+func parseLogLevel(name string, quiet bool) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("Некорректный уровень журналирования %q: %w", name, err)
+	}
+	if quiet && level < slog.LevelError {
+		level = slog.LevelError
+	}
+	return level, nil
 }
 
-// analyzeData анализирует данные о курсах валют и обновляет статистику в globalStats
-func analyzeData(valCurs ValCurs) {
-	for _, valute := range valCurs.Valutes {
-		valueStr := strings.Replace(valute.Value, ",", ".", -1) // Заменяем запятую на точку для преобразования в float
-		value, err := strconv.ParseFloat(valueStr, 64)
+// readDatesFile читает даты в формате ГГГГ-ММ-ДД из файла, по одной на строку.
+// Пустые строки пропускаются, некорректные журналируются и пропускаются.
+// Даты возвращаются в хронологическом порядке без повторов.
+func readDatesFile(path string) ([]time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при открытии файла дат: %w", err)
+	}
+	defer file.Close()
+
+	seen := make(map[time.Time]bool)
+	var dates []time.Time
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		d, err := time.Parse(flagDateLayout, text)
 		if err != nil {
-			fmt.Printf("Ошибка при преобразовании курса валюты %s: %v\n", valute.CharCode, err)
+			slog.Warn("Пропуск некорректной даты в файле", "path", path, "line", line, "value", text)
 			continue
 		}
+		if !seen[d] {
+			seen[d] = true
+			dates = append(dates, d)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Ошибка при чтении файла дат: %w", err)
+	}
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("В файле %s нет корректных дат", path)
+	}
 
-		// Добавление или обновление статистики по валюте в globalStats
-		stats, ok := globalStats[valute.CharCode]
-		if !ok {
-			globalStats[valute.CharCode] = &CurrencyStats{
-				MaxValue:     value,
-				MinValue:     value,
-				MaxDate:      valCurs.Date,
-				MinDate:      valCurs.Date,
-				TotalValue:   value,
-				Count:        1,
-				Nominal:      valute.Nominal,
-				CurrencyName: valute.Name,
-				NumCode:      valute.NumCode,
-				CharCode:     valute.CharCode,
-			}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+	return dates, nil
+}
+
+// requestURLs возвращает адреса, которые будут запрошены у источника курсов за указанные даты.
+// Лента ЕЦБ и динамика курса ЦБ РФ содержат историю за период и запрашиваются один раз.
+func requestURLs(source exchangerates.RateSource, dates []time.Time) []string {
+	switch s := source.(type) {
+	case *exchangerates.Fetcher:
+		urls := make([]string, 0, len(dates))
+		for _, d := range dates {
+			urls = append(urls, s.URL(d))
+		}
+		return urls
+	case *exchangerates.ECBSource:
+		return []string{s.URL}
+	case *exchangerates.DynamicSource:
+		return []string{s.RequestURL()}
+	default:
+		return nil
+	}
+}
+
+// writeRequestURLs выводит адреса запросов к источнику курсов за указанные даты по одному на строку
+func writeRequestURLs(w io.Writer, source exchangerates.RateSource, dates []time.Time) error {
+	for _, url := range requestURLs(source, dates) {
+		if _, err := fmt.Fprintln(w, url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkResult содержит результат проверки доступности источника курсов
+type checkResult struct {
+	URL     string        // Запрошенный адрес
+	Status  int           // HTTP-статус ответа; 0, если ответ не получен
+	Latency time.Duration // Время от отправки запроса до получения ответа
+	Err     error         // Ошибка запроса
+}
+
+func (r checkResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s: failed, status %d, latency %s: %v", r.URL, r.Status, r.Latency.Round(time.Millisecond), r.Err)
+	}
+	return fmt.Sprintf("%s: ok, status %d, latency %s", r.URL, r.Status, r.Latency.Round(time.Millisecond))
+}
+
+// checkSource выполняет один запрос к url без повторных попыток и измеряет время ответа
+func checkSource(ctx context.Context, client *http.Client, url string, header http.Header) checkResult {
+	start := time.Now()
+	_, err := exchangerates.FetchCurrencyRates(ctx, client, url, header)
+	result := checkResult{URL: url, Latency: time.Since(start), Err: err}
+
+	var statusErr *exchangerates.StatusError
+	switch {
+	case err == nil:
+		result.Status = http.StatusOK
+	case errors.As(err, &statusErr):
+		result.Status = statusErr.StatusCode
+	}
+	return result
+}
+
+// cacheSubdir возвращает каталог кэша ответов API внутри dir. Ответы на разных языках кэшируются
+// раздельно, а ответы сервера, заданного -base-url (зеркала или тестового сервера), — в отдельном
+// каталоге с коротким хешем адреса, чтобы не смешиваться с курсами ЦБ РФ.
+func cacheSubdir(dir, lang, baseURL string) string {
+	if baseURL == "" {
+		return filepath.Join(dir, lang)
+	}
+	sum := sha256.Sum256([]byte(baseURL))
+	return filepath.Join(dir, "url-"+hex.EncodeToString(sum[:6]))
+}
+
+// flagIsSet определяет, указан ли флаг name в командной строке явно
+func flagIsSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// isTerminal определяет, связан ли файл с терминалом
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// reportSummary выводит в стандартный поток ошибок сводку загрузки, если exchangerates.Run дошёл до подсчёта результатов
+func reportSummary(summary exchangerates.Summary, err error) {
+	if err == nil || errors.Is(err, exchangerates.ErrNoData) || errors.Is(err, exchangerates.ErrTooManyFailed) {
+		fmt.Fprintln(os.Stderr, summary)
+	}
+}
+
+// comparisonConfig возвращает конфигурацию загрузки курсов за период сравнения от start до end.
+// Статистика второго периода накапливается независимо от основной, а манифест, поток,
+// обработчик дней и метрика курсов относятся только к основному периоду.
+func comparisonConfig(cfg exchangerates.Config, start, end time.Time) exchangerates.Config {
+	cfg.Start, cfg.End = start, end
+	cfg.Dates = nil
+	cfg.ManifestPath = ""
+	cfg.Stream = nil
+	cfg.OnDay = nil
+	cfg.Metrics = false
+	cfg.Stats = exchangerates.NewStatsStore()
+	return cfg
+}
+
+func main() {
+	concurrency := flag.Int("concurrency", exchangerates.DefaultConcurrency, "Количество параллельных запросов к API")
+	format := flag.String("format", "", "Формат вывода статистики: text, table, json или csv; по умолчанию table для терминала и text иначе")
+	output := flag.String("output", "", "Путь к файлу для вывода статистики, по умолчанию стандартный вывод")
+	csvPath := flag.String("csv", "", "Путь к CSV-файлу для сохранения статистики")
+	headline := flag.Bool("headline", false, "Вывести валюты с наибольшим ростом и снижением курса к базовой валюте за период")
+	matrixPath := flag.String("matrix-out", "", "Путь к CSV-файлу с курсами по датам: строки — даты, столбцы — коды валют")
+	splitDir := flag.String("split-dir", "", "Каталог для сохранения курсов каждой валюты по датам в отдельный CSV-файл <код>.csv")
+	jsonPath := flag.String("json-out", "", "Путь к JSON-файлу для сохранения статистики независимо от -format")
+	startFlag := flag.String("start", "", "Начальная дата периода (ГГГГ-ММ-ДД), по умолчанию -since-days дней назад")
+	endFlag := flag.String("end", "", "Конечная дата периода (ГГГГ-ММ-ДД), по умолчанию сегодня")
+	sinceDays := flag.Int("since-days", defaultRangeDays, "Длина периода в днях до сегодняшнего дня; несовместим с -start и -end")
+	dayTimeout := flag.Duration("day-timeout", exchangerates.DefaultDayTimeout, "Максимальное время загрузки курсов за один день с учётом повторных попыток, 0 — без ограничения")
+	retries := flag.Int("retries", exchangerates.DefaultMaxRetries, "Максимальное количество повторных попыток запроса")
+	parseRetries := flag.Int("parse-retries", exchangerates.DefaultParseRetries, "Количество повторных загрузок ответа, который не удалось разобрать (например, обрезанного)")
+	maxTotalRetries := flag.Int("max-total-retries", 0, "Общее количество повторных попыток запросов за всю загрузку, 0 — без ограничения")
+	retryDelay := flag.Duration("retry-delay", exchangerates.DefaultRetryDelay, "Базовая задержка перед повторной попыткой запроса")
+	currencies := flag.String("currencies", "", "Список символьных кодов валют через запятую (например, USD,EUR), по умолчанию все")
+	basket := flag.String("basket", "", "Рассчитать индекс корзины валют с весами, например \"USD:0.6,EUR:0.4\"")
+	currenciesFile := flag.String("currencies-file", "", "Путь к файлу со списком кодов валют (по одному на строку, комментарии после #); объединяется с -currencies")
+	locale := flag.String("locale", "", "Локаль форматирования чисел в форматах text и table и в выводе -convert: ru (1 234,56) или en (1,234.56), по умолчанию без разделителей разрядов; форматы json и csv не меняются")
+	convert := flag.String("convert", "", "Пересчитать сумму по средним курсам, например \"100 USD EUR\"")
+	dbPath := flag.String("db", "", "Путь к базе данных SQLite для сохранения ежедневных курсов")
+	cacheDir := flag.String("cache-dir", exchangerates.DefaultCacheDir, "Каталог файлового кэша ответов API")
+	cacheTTL := flag.Duration("cache-ttl", exchangerates.DefaultCacheTTL, "Срок хранения в кэше ответов за текущую дату, после которого они загружаются заново; 0 — бессрочно")
+	parsedCacheSize := flag.Int("memory-cache", exchangerates.DefaultParsedCacheSize, "Количество дней, разобранные курсы за которые хранятся в памяти для повторных анализов, 0 — не хранить")
+	revalidate := flag.Bool("revalidate", false, "Проверять устаревшие (старше -cache-ttl) ответы за текущую дату условными запросами (ETag/Last-Modified) вместо полной загрузки")
+	noCache := flag.Bool("no-cache", false, "Не использовать файловый кэш ответов API")
+	sourceName := flag.String("source", "cbr", "Источник курсов валют: cbr (ЦБ РФ) или ecb (Европейский центральный банк)")
+	dynamic := flag.String("dynamic", "", "Загрузить динамику курса одной валюты ЦБ РФ за период одним запросом, например USD")
+	weekends := flag.Bool("weekends", false, "Запрашивать курсы и за выходные дни")
+	skipStale := flag.Bool("skip-stale", false, "Пропускать дни, за которые ЦБ РФ вернул курсы предыдущего рабочего дня")
+	profileAddr := flag.String("profile", "", "Адрес сервера профилирования net/http/pprof (например, :6060), по умолчанию выключен")
+	trace := flag.Bool("trace", false, "Журналировать время DNS, соединения, TLS и первого байта ответа каждого запроса (уровень debug)")
+	logLevel := flag.String("log-level", "info", "Уровень журналирования: debug, info, warn или error")
+	failFast := flag.Bool("fail-fast", false, "Завершать работу при первой ошибке загрузки или разбора курсов")
+	stream := flag.Bool("stream", false, "Выводить курсы каждого обработанного дня в формате JSON Lines; итоговая статистика выводится только в файл -output")
+	quiet := flag.Bool("quiet", false, "Не выводить сообщения об ошибках отдельных дней, только итоговую сводку и статистику")
+	minCov := flag.Float64("min-coverage", 0, "Минимальная доля дней периода с курсом валюты (0–1), при которой валюта выводится")
+	top := flag.Int("top", 0, "Вывести только N валют с наибольшей волатильностью, 0 — все валюты")
+	serveAddr := flag.String("serve", "", "Адрес HTTP API со статистикой (например, :8080); пустое значение отключает сервер")
+	base := flag.String("base", "", "Базовая валюта, относительно которой пересчитываются курсы (например, USD), по умолчанию валюта источника: RUB для cbr, EUR для ecb")
+	maxFailed := flag.Float64("max-failed", exchangerates.DefaultMaxFailedRatio, "Допустимая доля дней с ошибками загрузки (0–1), при превышении код выхода ненулевой")
+	baseURLFlag := flag.String("base-url", "", "Шаблон адреса API ЦБ РФ с параметром даты %s (дд/мм/гггг), по умолчанию выбирается по -lang")
+	lang := flag.String("lang", "en", "Язык названий валют ЦБ РФ: ru или en")
+	chartCode := flag.String("chart", "", "Символьный код валюты для построения графика курса (например, USD)")
+	chartOut := flag.String("chart-out", "", "Путь к PNG-файлу графика, по умолчанию <код>.png")
+	compare := flag.String("compare", "", "Сравнить средние курсы периода с периодом вида ГГГГ-ММ-ДД:ГГГГ-ММ-ДД")
+	insecure := flag.Bool("insecure-skip-verify", false, "Не проверять сертификаты HTTPS (только для отладки за прокси, подменяющими TLS)")
+	proxy := flag.String("proxy", "", "Адрес прокси-сервера (http, https или socks5), по умолчанию из переменных окружения HTTP_PROXY/HTTPS_PROXY")
+	userAgent := flag.String("user-agent", exchangerates.DefaultUserAgent, "Заголовок User-Agent запросов к API")
+	batchSize := flag.Int("batch-size", 0, "Количество дат, загружаемых пакетом перед паузой -batch-pause, 0 — без разбиения на пакеты")
+	batchPause := flag.Duration("batch-pause", time.Second, "Пауза между пакетами загрузки -batch-size")
+	rateLimit := flag.Float64("rate-limit", defaultRateLimit, "Максимальное количество запросов к API в секунду, 0 — без ограничения")
+	outlierPercent := flag.Float64("outlier-percent", exchangerates.DefaultOutlierPercent, "Изменение курса за день в процентах, после которого день считается выбросом, 0 — не проверять")
+	dropOutliers := flag.Bool("drop-outliers", false, "Исключать выбросы из статистики")
+	onlyChanges := flag.Bool("only-changes", false, "Выводить по каждой валюте только даты изменения курса с прежним и новым значением")
+	groupBy := flag.String("group-by", "", "Группировать валюты: region — по регионам со средним изменением курса")
+	intervalFlag := flag.String("interval", "", "Группировать статистику по интервалам: day, week или month; по умолчанию за весь период")
+	maWindow := flag.Int("ma-window", defaultMAWindow, "Окно скользящего среднего в днях, 0 — не рассчитывать")
+	exact := flag.Bool("exact", false, "Рассчитывать среднее значение курса точно, суммируя значения как рациональные числа")
+	perUnit := flag.Bool("per-unit", false, "Выводить все курсы за одну единицу валюты вместо курса за номинал")
+	precision := flag.Int("precision", defaultPrecision, "Количество знаков после запятой в значениях курсов")
+	datesFile := flag.String("dates-file", "", "Путь к файлу со списком дат (ГГГГ-ММ-ДД, по одной на строку) вместо периода")
+	inputDir := flag.String("input-dir", "", "Каталог с сохранёнными XML-ответами ЦБ РФ для анализа без загрузки")
+	manifestPath := flag.String("manifest", "", "Путь к JSON-файлу со списком обработанных дней и источником их курсов")
+	validateFlag := flag.Bool("validate", false, "Проверить полноту и корректность курсов за период без расчёта статистики; код выхода 1 при ошибках")
+	listCurrencies := flag.Bool("list-currencies", false, "Вывести коды и названия валют, опубликованных за -end (по умолчанию сегодня)")
+	check := flag.Bool("check", false, "Проверить доступность источника одним запросом курсов за -end (по умолчанию сегодня) и вывести статус и время ответа")
+	dryRun := flag.Bool("dry-run", false, "Вывести адреса запросов к API без загрузки и анализа курсов")
+	var headers headerFlags
+	flag.Var(&headers, "header", "Дополнительный заголовок запроса вида \"Имя: значение\" (можно указать несколько раз)")
+	flag.Parse()
+
+	level, err := parseLogLevel(*logLevel, *quiet)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	if *profileAddr != "" {
+		// Ошибка запуска профилирования не мешает основной работе
+		addr, err := startProfiler(*profileAddr)
+		if err != nil {
+			slog.Warn("Не удалось запустить сервер профилирования", "addr", *profileAddr, "error", err)
 		} else {
-			stats.TotalValue += value
-			stats.Count++
-			if value > stats.MaxValue {
-				stats.MaxValue = value
-				stats.MaxDate = valCurs.Date
+			slog.Info("Сервер профилирования запущен", "url", "http://"+addr+"/debug/pprof/")
+		}
+	}
+
+	if *minCov < 0 || *minCov > 1 {
+		slog.Error("Некорректная минимальная доля дней", "min-coverage", *minCov)
+		os.Exit(2)
+	}
+
+	if *groupBy != "" && *groupBy != "region" {
+		slog.Error("Неизвестная группировка", "group-by", *groupBy)
+		os.Exit(2)
+	}
+
+	var interval exchangerates.Interval
+	if *intervalFlag != "" {
+		var err error
+		interval, err = exchangerates.ParseInterval(*intervalFlag)
+		if err != nil {
+			slog.Error("Некорректный интервал группировки", "error", err)
+			os.Exit(2)
+		}
+	}
+
+	if *maWindow < 0 {
+		slog.Error("Некорректное окно скользящего среднего", "ma-window", *maWindow)
+		os.Exit(2)
+	}
+
+	if *precision < 0 {
+		slog.Error("Некорректное количество знаков после запятой", "precision", *precision)
+		os.Exit(2)
+	}
+
+	if *format == "" {
+		*format = "text"
+		if *output == "" && isTerminal(os.Stdout) {
+			*format = "table"
+		}
+	}
+	if *format != "text" && *format != "table" && *format != "json" && *format != "csv" {
+		slog.Error("Неизвестный формат вывода", "format", *format)
+		os.Exit(2)
+	}
+
+	currencyList := parseCurrencyList(*currencies)
+	if *currenciesFile != "" {
+		codes, err := readCurrenciesFile(*currenciesFile)
+		if err != nil {
+			slog.Error("Не удалось прочитать список валют", "error", err)
+			os.Exit(2)
+		}
+		currencyList = parseCurrencyList(strings.Join(append(currencyList, codes...), ","))
+	}
+
+	printer, err := newAmountPrinter(*locale)
+	if err != nil {
+		slog.Error("Некорректная локаль вывода", "error", err)
+		os.Exit(2)
+	}
+
+	var weights map[string]float64
+	if *basket != "" {
+		var err error
+		weights, err = parseBasket(*basket)
+		if err != nil {
+			slog.Error("Некорректный состав корзины валют", "error", err)
+			os.Exit(2)
+		}
+	}
+
+	var amount float64
+	var from, to string
+	if *convert != "" {
+		var err error
+		amount, from, to, err = parseConversion(*convert)
+		if err != nil {
+			slog.Error("Некорректный запрос на пересчёт", "error", err)
+			os.Exit(2)
+		}
+	}
+
+	header, err := parseHeaders(*userAgent, headers)
+	if err != nil {
+		slog.Error("Некорректный заголовок запроса", "error", err)
+		os.Exit(2)
+	}
+
+	if flagIsSet("since-days") && (*startFlag != "" || *endFlag != "") {
+		slog.Error("Флаг -since-days нельзя использовать вместе с -start и -end")
+		os.Exit(2)
+	}
+	if *datesFile != "" && (flagIsSet("since-days") || *startFlag != "" || *endFlag != "") {
+		slog.Error("Флаг -dates-file нельзя использовать вместе с -start, -end и -since-days")
+		os.Exit(2)
+	}
+	if *listCurrencies && *startFlag == "" {
+		*startFlag = *endFlag // Для списка валют нужен только один день
+	}
+	startDate, endDate, err := parseDateRange(*startFlag, *endFlag, *sinceDays, time.Now())
+	if err != nil {
+		slog.Error("Некорректный период", "error", err)
+		os.Exit(2)
+	}
+
+	var dates []time.Time
+	if *datesFile != "" {
+		dates, err = readDatesFile(*datesFile)
+		if err != nil {
+			slog.Error("Не удалось прочитать список дат", "error", err)
+			os.Exit(2)
+		}
+		startDate, endDate = dates[0], dates[len(dates)-1]
+	}
+
+	var compareStart, compareEnd time.Time
+	if *compare != "" {
+		compareStart, compareEnd, err = parseCompareRange(*compare)
+		if err != nil {
+			slog.Error("Некорректный период сравнения", "error", err)
+			os.Exit(2)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt) // Отмена запросов по SIGINT
+	defer stop()
+
+	proxyURL, err := parseProxy(*proxy)
+	if err != nil {
+		slog.Error("Некорректный адрес прокси", "error", err)
+		os.Exit(2)
+	}
+	if *insecure {
+		slog.Warn("Проверка сертификатов HTTPS отключена")
+	}
+	client := exchangerates.NewHTTPClient(exchangerates.DefaultTimeout, proxyURL, *insecure) // Общий клиент для всех запросов к API
+	if *trace {
+		exchangerates.EnableTracing(client)
+	}
+	var source exchangerates.RateSource
+	switch *sourceName {
+	case "cbr":
+		var baseURL string
+		switch *lang {
+		case "en":
+			baseURL = exchangerates.CBRDailyEngURL
+		case "ru":
+			baseURL = exchangerates.CBRDailyURL
+		default:
+			slog.Error("Неизвестный язык", "lang", *lang)
+			os.Exit(2)
+		}
+		if *baseURLFlag != "" {
+			if strings.Count(*baseURLFlag, "%s") != 1 {
+				slog.Error("Шаблон адреса API должен содержать ровно один параметр даты %s", "base-url", *baseURLFlag)
+				os.Exit(2)
 			}
-			if value < stats.MinValue {
-				stats.MinValue = value
-				stats.MinDate = valCurs.Date
+			if !strings.HasPrefix(*baseURLFlag, "http://") && !strings.HasPrefix(*baseURLFlag, "https://") {
+				slog.Error("Шаблон адреса API должен начинаться с http:// или https://", "base-url", *baseURLFlag)
+				os.Exit(2)
 			}
+			baseURL = *baseURLFlag
+		}
+
+		fetcher := &exchangerates.Fetcher{
+			BaseURL:      baseURL,
+			Client:       client,
+			MaxRetries:   *retries,
+			ParseRetries: *parseRetries,
+			RetryDelay:   *retryDelay,
+			SkipStale:    *skipStale,
+			Header:       header,
+			Revalidate:   *revalidate,
 		}
+		if *parsedCacheSize > 0 {
+			fetcher.Parsed = exchangerates.NewParsedCache(*parsedCacheSize)
+		}
+		if *maxTotalRetries > 0 {
+			fetcher.Budget = exchangerates.NewRetryBudget(*maxTotalRetries)
+		}
+		if *rateLimit > 0 {
+			fetcher.Limiter = rate.NewLimiter(rate.Limit(*rateLimit), 1)
+		}
+		if !*noCache {
+			fetcher.CacheDir = cacheSubdir(*cacheDir, *lang, *baseURLFlag)
+			fetcher.CacheTTL = *cacheTTL
+		}
+		source = fetcher
+	case "ecb":
+		source = &exchangerates.ECBSource{URL: exchangerates.DefaultECBURL, Client: client, Header: header}
+	default:
+		slog.Error("Неизвестный источник курсов", "source", *sourceName)
+		os.Exit(2)
 	}
-}
 
-func main() {
-	baseUrl := "http://www.cbr.ru/scripts/XML_daily_eng.asp?date_req=%s"
-	startDate := time.Now().AddDate(0, 0, -90)
+	if *dynamic != "" {
+		if *sourceName != "cbr" {
+			slog.Error("Флаг -dynamic поддерживается только для источника cbr")
+			os.Exit(2)
+		}
+		code := strings.ToUpper(*dynamic)
+		id, err := exchangerates.CBRCurrencyID(code)
+		if err != nil {
+			slog.Error("Некорректная валюта для загрузки динамики курса", "error", err)
+			os.Exit(2)
+		}
+		source = &exchangerates.DynamicSource{
+			URL:      exchangerates.CBRDynamicURL,
+			Client:   client,
+			Header:   header,
+			CharCode: code,
+			ID:       id,
+			Start:    startDate,
+			End:      endDate,
+		}
+	}
 
-	for d := startDate; d.Before(time.Now()); d = d.AddDate(0, 0, 1) {
-		dateStr := d.Format("08/03/2024") // Форматирование даты для запроса
-		url := fmt.Sprintf(baseUrl, dateStr)
+	cfg := exchangerates.NewConfig(source, startDate, endDate)
+	cfg.Weekends = *weekends
+	cfg.Dates = dates
+	cfg.Concurrency = *concurrency
+	cfg.DayTimeout = *dayTimeout
+	cfg.InputDir = *inputDir
+	cfg.DBPath = *dbPath
+	cfg.Base = *base
+	cfg.Currencies = currencyList
+	cfg.Exact = *exact
+	cfg.MaxFailedRatio = *maxFailed
+	cfg.OutlierPercent = *outlierPercent
+	cfg.DropOutliers = *dropOutliers
+	cfg.ManifestPath = *manifestPath
+	cfg.FailFast = *failFast
+	cfg.Metrics = true
+	cfg.BatchSize = *batchSize
+	cfg.BatchPause = *batchPause
 
-		xmlData, err := fetchCurrencyRates(url) // Получение данных о курсах валют
+	if *stream {
+		cfg.Stream = os.Stdout
+	}
+
+	if *validateFlag {
+		if cfg.InputDir != "" {
+			slog.Error("Флаг -validate нельзя использовать вместе с -input-dir")
+			os.Exit(2)
+		}
+		report, err := validate(ctx, cfg)
 		if err != nil {
-			fmt.Println(err)
-			continue
+			slog.Error("Не удалось проверить курсы", "error", err)
+			os.Exit(1)
 		}
+		if err := writeValidation(os.Stdout, report); err != nil {
+			slog.Error("Не удалось вывести результаты проверки", "error", err)
+			os.Exit(1)
+		}
+		if !report.ok() {
+			os.Exit(1)
+		}
+		return
+	}
 
-		valCurs, err := parseXML(xmlData) // Разбор полученных данных
+	if *listCurrencies {
+		// Курсы запрашиваются за один день -end (по умолчанию сегодня) без расчёта статистики
+		valCurs, err := source.FetchRates(ctx, endDate)
 		if err != nil {
-			fmt.Printf("Ошибка при разборе XML для даты %s: %v\n", dateStr, err)
-			continue
+			slog.Error("Не удалось получить список валют", "error", err)
+			os.Exit(1)
 		}
+		if err := writeCurrencyList(os.Stdout, valCurs); err != nil {
+			slog.Error("Не удалось вывести список валют", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-		analyzeData(valCurs) // Анализ данных и обновление статистики
+	if *check {
+		// Курсы за сегодня запрашиваются один раз, без кэша и повторных попыток
+		urls := requestURLs(source, []time.Time{endDate})
+		if len(urls) == 0 {
+			slog.Error("Проверка доступности не поддерживается для источника", "source", *sourceName)
+			os.Exit(2)
+		}
+		result := checkSource(ctx, client, urls[0], header)
+		fmt.Println(result)
+		if result.Err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *dryRun {
+		if err := writeRequestURLs(os.Stdout, source, cfg.RequestDates()); err != nil {
+			slog.Error("Не удалось вывести адреса запросов", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	snapshot, summary, runErr := exchangerates.Run(ctx, cfg)
+	if ctx.Err() != nil {
+		stop() // После прерывания повторный SIGINT во время вывода результатов завершает программу сразу
+	}
+	reportSummary(summary, runErr)
+	if runErr != nil && !errors.Is(runErr, exchangerates.ErrTooManyFailed) {
+		slog.Error("Не удалось получить статистику", "error", runErr)
+		os.Exit(1)
+	}
+
+	if *stream && *output == "" {
+		// Стандартный вывод занят потоком курсов по дням, поэтому итоговая статистика не выводится
+		if runErr != nil {
+			slog.Error("Не удалось получить статистику", "error", runErr)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *serveAddr != "" {
+		// SIGINT до этого момента прерывает загрузку, поэтому сервер получает новый контекст
+		serveCtx, stopServe := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stopServe()
+		if err := serve(serveCtx, *serveAddr, cfg.Stats); err != nil {
+			slog.Error("Ошибка HTTP API", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			slog.Error("Не удалось создать файл вывода", "error", err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	if *perUnit {
+		snapshot = toUnit(snapshot)
+	}
+
+	if *compare != "" {
+		other, otherSummary, err := exchangerates.Run(ctx, comparisonConfig(cfg, compareStart, compareEnd))
+		reportSummary(otherSummary, err)
+		if err != nil && !errors.Is(err, exchangerates.ErrTooManyFailed) {
+			slog.Error("Не удалось получить статистику за период сравнения", "error", err)
+			os.Exit(1)
+		}
+		if *perUnit {
+			other = toUnit(other)
+		}
+		if err := writeComparison(out, printer, snapshot, other, *format, *precision); err != nil {
+			slog.Error("Не удалось вывести сравнение", "error", err)
+			os.Exit(1)
+		}
+		if err == nil {
+			err = runErr
+		}
+		if err != nil {
+			slog.Error("Не удалось получить статистику", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	snapshot = topVolatile(minCoverage(snapshot, *minCov), *top)
+	if weights != nil {
+		// Корзина рассчитывается по всем валютам независимо от отбора -top и -min-coverage
+		var points []exchangerates.RatePoint
+		points, err = exchangerates.Basket(cfg.Stats.Snapshot(), weights)
+		if err == nil {
+			err = writeBasket(out, printer, points, *format, *precision)
+		}
+	} else if *onlyChanges {
+		err = writeChanges(out, printer, snapshot, *format, *precision)
+	} else if *groupBy == "region" {
+		err = writeRegions(out, printer, exchangerates.GroupByRegion(snapshot), *format, *precision, *maWindow)
+	} else if interval != "" {
+		err = writeIntervals(out, printer, exchangerates.GroupByInterval(snapshot, interval), *format, *precision, *maWindow)
+	} else {
+		err = writeStats(out, printer, snapshot, *format, *precision, *maWindow)
+	}
+	if err != nil {
+		slog.Error("Не удалось вывести статистику", "error", err)
+		os.Exit(1)
+	}
+
+	if *csvPath != "" {
+		if err := writeStatsFile(*csvPath, snapshot, "csv", *precision, *maWindow); err != nil {
+			slog.Error("Не удалось сохранить CSV-файл", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if *jsonPath != "" {
+		if err := writeStatsFile(*jsonPath, snapshot, "json", *precision, *maWindow); err != nil {
+			slog.Error("Не удалось сохранить JSON-файл", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if *headline {
+		if err := writeHeadline(out, printer, snapshot, cfg.Stats.Base()); err != nil {
+			slog.Error("Не удалось вывести сводку", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if *matrixPath != "" {
+		if err := writeMatrixFile(*matrixPath, snapshot, *precision); err != nil {
+			slog.Error("Не удалось сохранить таблицу курсов по датам", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if *splitDir != "" {
+		if err := writeSplitCSV(*splitDir, snapshot, *precision); err != nil {
+			slog.Error("Не удалось сохранить CSV-файлы по валютам", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if *chartCode != "" {
+		code := strings.ToUpper(*chartCode)
+		path := *chartOut
+		if path == "" {
+			path = strings.ToLower(code) + ".png"
+		}
+
+		stats, ok := cfg.Stats.Snapshot()[code]
+		if !ok {
+			slog.Error("Нет данных для построения графика", "code", code)
+			os.Exit(1)
+		}
+		if *perUnit {
+			stats = stats.ToUnit()
+		}
+		if err := writeChart(path, stats); err != nil {
+			slog.Error("Не удалось построить график", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if *convert != "" {
+		result, err := cfg.Stats.Convert(amount, from, to)
+		if err != nil {
+			slog.Error("Не удалось пересчитать сумму", "error", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(out, "%s %s = %s %s\n", formatAmount(printer, amount, *precision), from, formatAmount(printer, result, *precision), to)
 	}
 
-	// Вывод собранной статистики по каждой валюте
-	for _, stats := range globalStats {
-		stats.Average = stats.TotalValue / float64(stats.Count) // Расчёт среднего значения курса
-		fmt.Printf("%s (%s, %s) - Nominal: %d, Max: %f (%s), Min: %f (%s), Average: %f\n",
-			stats.CurrencyName, stats.CharCode, stats.NumCode, stats.Nominal,
-			stats.MaxValue, stats.MaxDate, stats.MinValue, stats.MinDate, stats.Average)
+	if runErr != nil {
+		slog.Error("Не удалось получить статистику", "error", runErr)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Alfarabi09/Exchange_Rates/exchangerates"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// defaultPrecision задаёт количество знаков после запятой для значений курсов по умолчанию
+const defaultPrecision = 4
+
+// roundTo округляет значение до заданного количества знаков после запятой
+func roundTo(v float64, precision int) float64 {
+	p := math.Pow(10, float64(precision))
+	return math.Round(v*p) / p
+}
+
+// newAmountPrinter возвращает форматировщик денежных сумм для локали ru (1 234,56)
+// или en (1,234.56). Для пустой локали возвращается nil: суммы выводятся без разделителей разрядов.
+func newAmountPrinter(locale string) (*message.Printer, error) {
+	switch locale {
+	case "":
+		return nil, nil
+	case "ru":
+		return message.NewPrinter(language.Russian), nil
+	case "en":
+		return message.NewPrinter(language.English), nil
+	default:
+		return nil, fmt.Errorf("Неизвестная локаль %q, ожидается ru или en", locale)
+	}
+}
+
+// formatAmount форматирует денежную сумму с precision знаками после запятой
+// согласно локали форматировщика p (см. newAmountPrinter)
+func formatAmount(p *message.Printer, v float64, precision int) string {
+	if p == nil {
+		return strconv.FormatFloat(v, 'f', precision, 64)
+	}
+	return p.Sprintf("%.*f", precision, v)
+}
+
+// fprintf выводит строку по формату format через форматировщик p с разделителями разрядов
+// и десятичным разделителем его локали или через fmt при p == nil (см. newAmountPrinter)
+func fprintf(w io.Writer, p *message.Printer, format string, a ...any) (int, error) {
+	if p == nil {
+		return fmt.Fprintf(w, format, a...)
+	}
+	return p.Fprintf(w, format, a...)
+}
+
+// topVolatile возвращает n валют с наибольшей волатильностью (см. CurrencyStats.Volatility).
+// При равной волатильности валюты упорядочиваются по символьному коду. При n <= 0 возвращаются все валюты.
+func topVolatile(stats map[string]exchangerates.CurrencyStats, n int) map[string]exchangerates.CurrencyStats {
+	if n <= 0 || n >= len(stats) {
+		return stats
+	}
+
+	// Стабильная сортировка сохраняет порядок по символьному коду для равной волатильности
+	list := exchangerates.SortedStats(stats)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Volatility() > list[j].Volatility()
+	})
+
+	top := make(map[string]exchangerates.CurrencyStats, n)
+	for _, s := range list[:n] {
+		top[s.CharCode] = s
+	}
+	return top
+}
+
+// coverage возвращает покрытие периода данными о курсе валюты вида "60/90",
+// отмечая неполное покрытие звёздочкой
+func coverage(s exchangerates.CurrencyStats) string {
+	c := fmt.Sprintf("%d/%d", s.Count, s.DaysInRange)
+	if !s.Complete() {
+		c += "*"
+	}
+	return c
+}
+
+// minCoverage возвращает валюты, курсы которых есть не менее чем за долю threshold учтённых дат периода
+// (см. CurrencyStats.Coverage). При threshold <= 0 возвращаются все валюты.
+func minCoverage(stats map[string]exchangerates.CurrencyStats, threshold float64) map[string]exchangerates.CurrencyStats {
+	if threshold <= 0 {
+		return stats
+	}
+
+	filtered := make(map[string]exchangerates.CurrencyStats, len(stats))
+	for code, s := range stats {
+		if s.Coverage() >= threshold {
+			filtered[code] = s
+		}
+	}
+	return filtered
+}
+
+// toUnit пересчитывает статистику всех валют за одну единицу валюты (см. CurrencyStats.ToUnit)
+func toUnit(stats map[string]exchangerates.CurrencyStats) map[string]exchangerates.CurrencyStats {
+	result := make(map[string]exchangerates.CurrencyStats, len(stats))
+	for code, s := range stats {
+		result[code] = s.ToUnit()
+	}
+	return result
+}
+
+// nominal возвращает номинал валюты для вывода с пометкой, если курсы пересчитаны за единицу валюты
+func nominal(s exchangerates.CurrencyStats) string {
+	if s.PerUnit {
+		return "1 (per unit)"
+	}
+	return strconv.Itoa(s.Nominal)
+}
+
+// writeText выводит статистику по валютам в человекочитаемом виде
+// со значениями курсов, округлёнными до precision знаков после запятой,
+// и последним значением скользящего среднего по окну window (при window > 0)
+func writeText(w io.Writer, p *message.Printer, stats map[string]exchangerates.CurrencyStats, precision, window int) error {
+	for _, s := range exchangerates.SortedStats(stats) {
+		_, err := fprintf(w, p, "%s (%s, %s) - Nominal: %s, Max: %.*f (%s), Min: %.*f (%s), Average: %.*f (%.*f per unit), Median: %.*f, StdDev: %.*f, First: %.*f, Last: %.*f, Change: %.*f (%+.2f%%, %s), Days: %s",
+			s.CurrencyName, s.CharCode, s.NumCode, nominal(s),
+			precision, s.MaxValue, s.MaxDate, precision, s.MinValue, s.MinDate, precision, s.Average, precision, s.UnitAverage(),
+			precision, s.Median(), precision, s.StdDev(), precision, s.First(), precision, s.Last(),
+			precision, s.Change(), s.ChangePercent(), s.Trend(), coverage(s))
+		if err != nil {
+			return err
+		}
+
+		if ma := s.MovingAverage(window); len(ma) > 0 {
+			_, err = fprintf(w, p, ", MA%d: %.*f", window, precision, ma[len(ma)-1].Value)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTable выводит статистику по валютам в виде таблицы с выровненными столбцами
+func writeTable(w io.Writer, p *message.Printer, stats map[string]exchangerates.CurrencyStats, precision int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Code\tName\tNominal\tMax\tMin\tAvg\tAvg/Unit\tDays")
+	for _, s := range exchangerates.SortedStats(stats) {
+		fprintf(tw, p, "%s\t%s\t%s\t%.*f\t%.*f\t%.*f\t%.*f\t%s\n",
+			s.CharCode, s.CurrencyName, nominal(s),
+			precision, s.MaxValue, precision, s.MinValue, precision, s.Average, precision, s.UnitAverage(), coverage(s))
+	}
+	return tw.Flush()
+}
+
+// strongestWeakest возвращает валюты с наибольшим ростом и наибольшим снижением курса
+// за период в процентах (см. CurrencyStats.ChangePercent). Учитываются валюты с курсами
+// хотя бы за две даты; при равном изменении выбирается валюта с меньшим символьным кодом.
+func strongestWeakest(stats map[string]exchangerates.CurrencyStats) (strongest, weakest exchangerates.CurrencyStats, ok bool) {
+	for _, s := range exchangerates.SortedStats(stats) {
+		if len(s.Series) < 2 {
+			continue
+		}
+		if !ok {
+			strongest, weakest, ok = s, s, true
+			continue
+		}
+		if s.ChangePercent() > strongest.ChangePercent() {
+			strongest = s
+		}
+		if s.ChangePercent() < weakest.ChangePercent() {
+			weakest = s
+		}
+	}
+	return strongest, weakest, ok
+}
+
+// writeHeadline выводит одной строкой валюты, сильнее всего укрепившуюся и ослабевшую
+// к базовой валюте base за период
+func writeHeadline(w io.Writer, p *message.Printer, stats map[string]exchangerates.CurrencyStats, base string) error {
+	strongest, weakest, ok := strongestWeakest(stats)
+	if !ok {
+		_, err := fmt.Fprintln(w, "Headline: not enough data")
+		return err
+	}
+	_, err := fprintf(w, p, "Headline (against %s): strongest %s (%+.2f%%), weakest %s (%+.2f%%)\n",
+		base, strongest.CharCode, strongest.ChangePercent(), weakest.CharCode, weakest.ChangePercent())
+	return err
+}
+
+// writeCurrencyList выводит таблицу валют из ответа за день, упорядоченную по символьному коду
+func writeCurrencyList(w io.Writer, valCurs exchangerates.ValCurs) error {
+	valutes := slices.Clone(valCurs.Valutes)
+	sort.Slice(valutes, func(i, j int) bool {
+		return valutes[i].CharCode < valutes[j].CharCode
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Code\tNumCode\tName")
+	for _, v := range valutes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", v.CharCode, v.NumCode, v.Name)
+	}
+	return tw.Flush()
+}
+
+// comparisonJSON описывает изменение среднего курса валюты между двумя периодами
+// в JSON-выводе сравнения
+type comparisonJSON struct {
+	CharCode      string  `json:"char_code"`      // Символьный код валюты
+	Name          string  `json:"name"`           // Название валюты
+	FirstAverage  float64 `json:"first_average"`  // Средний курс за первый период
+	SecondAverage float64 `json:"second_average"` // Средний курс за второй период
+	Delta         float64 `json:"delta"`          // Абсолютное изменение среднего курса
+	ChangePercent float64 `json:"change_percent"` // Относительное изменение среднего курса в процентах
+}
+
+// compareAverages возвращает изменение средних курсов валют, курсы которых есть в обоих периодах,
+// в порядке символьных кодов
+func compareAverages(first, second map[string]exchangerates.CurrencyStats) []comparisonJSON {
+	var list []comparisonJSON
+	for _, a := range exchangerates.SortedStats(first) {
+		b, ok := second[a.CharCode]
+		if !ok {
+			continue
+		}
+
+		delta := b.Average - a.Average
+		var percent float64
+		if a.Average != 0 {
+			percent = delta / a.Average * 100
+		}
+		list = append(list, comparisonJSON{
+			CharCode:      a.CharCode,
+			Name:          a.CurrencyName,
+			FirstAverage:  a.Average,
+			SecondAverage: b.Average,
+			Delta:         delta,
+			ChangePercent: percent,
+		})
+	}
+	return list
+}
+
+// writeComparison выводит средние курсы валют за два периода с абсолютным и относительным
+// изменением в формате text, table, json или csv. Форматы text и table выводят таблицу
+// с выровненными столбцами. Выводятся только валюты, курсы которых есть в обоих периодах.
+func writeComparison(w io.Writer, p *message.Printer, first, second map[string]exchangerates.CurrencyStats, format string, precision int) error {
+	list := compareAverages(first, second)
+	switch format {
+	case "text", "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "Code\tName\tAvg1\tAvg2\tDelta\tChange%")
+		for _, c := range list {
+			fprintf(tw, p, "%s\t%s\t%.*f\t%.*f\t%+.*f\t%+.2f\n",
+				c.CharCode, c.Name, precision, c.FirstAverage, precision, c.SecondAverage, precision, c.Delta, c.ChangePercent)
+		}
+		return tw.Flush()
+	case "json":
+		for i := range list {
+			c := &list[i]
+			c.FirstAverage, c.SecondAverage = roundTo(c.FirstAverage, precision), roundTo(c.SecondAverage, precision)
+			c.Delta, c.ChangePercent = roundTo(c.Delta, precision), roundTo(c.ChangePercent, 2)
+		}
+		if list == nil {
+			list = []comparisonJSON{}
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"CharCode", "Name", "FirstAverage", "SecondAverage", "Delta", "ChangePercent"}); err != nil {
+			return err
+		}
+		for _, c := range list {
+			if err := writer.Write([]string{
+				c.CharCode, c.Name,
+				strconv.FormatFloat(c.FirstAverage, 'f', precision, 64),
+				strconv.FormatFloat(c.SecondAverage, 'f', precision, 64),
+				strconv.FormatFloat(c.Delta, 'f', precision, 64),
+				strconv.FormatFloat(c.ChangePercent, 'f', 2, 64),
+			}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("Неизвестный формат вывода: %s", format)
+	}
+}
+
+// statsJSON описывает статистику по валюте в JSON-выводе вместе с производными показателями
+type statsJSON struct {
+	exchangerates.CurrencyStats
+	Median        float64                   `json:"median"`                   // Медиана значений курса
+	StdDev        float64                   `json:"std_dev"`                  // Стандартное отклонение значений курса
+	First         float64                   `json:"first"`                    // Значение курса за первую дату периода
+	Last          float64                   `json:"last"`                     // Значение курса за последнюю дату периода
+	Change        float64                   `json:"change"`                   // Абсолютное изменение курса за период
+	ChangePercent float64                   `json:"change_percent"`           // Изменение курса за период в процентах
+	Trend         exchangerates.Trend       `json:"trend"`                    // Направление изменения курса
+	Complete      bool                      `json:"complete"`                 // Есть ли курс за каждую учтённую дату периода
+	UnitAverage   float64                   `json:"unit_average"`             // Средний курс одной единицы валюты
+	UnitMax       float64                   `json:"unit_max"`                 // Максимальный курс одной единицы валюты
+	UnitMin       float64                   `json:"unit_min"`                 // Минимальный курс одной единицы валюты
+	MovingAverage []exchangerates.RatePoint `json:"moving_average,omitempty"` // Ряд скользящих средних курса
+}
+
+// newStatsJSON подготавливает статистику по валюте к JSON-выводу, округляя значения курсов
+// до precision знаков после запятой. При window > 0 добавляется ряд скользящих средних.
+func newStatsJSON(s exchangerates.CurrencyStats, precision, window int) statsJSON {
+	item := statsJSON{
+		CurrencyStats: s,
+		Median:        roundTo(s.Median(), precision),
+		StdDev:        roundTo(s.StdDev(), precision),
+		First:         roundTo(s.First(), precision),
+		Last:          roundTo(s.Last(), precision),
+		Change:        roundTo(s.Change(), precision),
+		ChangePercent: roundTo(s.ChangePercent(), precision),
+		Trend:         s.Trend(),
+		Complete:      s.Complete(),
+		UnitAverage:   roundTo(s.UnitAverage(), precision),
+		UnitMax:       roundTo(s.UnitMax(), precision),
+		UnitMin:       roundTo(s.UnitMin(), precision),
+	}
+	for _, p := range s.MovingAverage(window) {
+		item.MovingAverage = append(item.MovingAverage, exchangerates.RatePoint{Date: p.Date, Value: roundTo(p.Value, precision)})
+	}
+	item.MaxValue = roundTo(s.MaxValue, precision)
+	item.MinValue = roundTo(s.MinValue, precision)
+	item.Average = roundTo(s.Average, precision)
+	return item
+}
+
+// writeJSON выводит статистику по валютам в виде JSON-массива с округлёнными значениями курсов
+func writeJSON(w io.Writer, stats map[string]exchangerates.CurrencyStats, precision, window int) error {
+	list := make([]statsJSON, 0, len(stats))
+	for _, s := range exchangerates.SortedStats(stats) {
+		list = append(list, newStatsJSON(s, precision, window))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(list)
+}
+
+// WriteCSV записывает статистику по валютам в формате CSV: строку заголовка и по строке на каждую валюту.
+// Значения курсов записываются с precision знаками после запятой.
+func WriteCSV(w io.Writer, stats map[string]exchangerates.CurrencyStats, precision int) error {
+	writer := csv.NewWriter(w)
+	header := []string{"CharCode", "NumCode", "Name", "Nominal", "Max", "MaxDate", "Min", "MinDate", "Average", "UnitAverage", "Median", "StdDev"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, s := range exchangerates.SortedStats(stats) {
+		record := []string{
+			s.CharCode,
+			s.NumCode,
+			s.CurrencyName,
+			strconv.Itoa(s.Nominal),
+			strconv.FormatFloat(s.MaxValue, 'f', precision, 64),
+			s.MaxDate,
+			strconv.FormatFloat(s.MinValue, 'f', precision, 64),
+			s.MinDate,
+			strconv.FormatFloat(s.Average, 'f', precision, 64),
+			strconv.FormatFloat(s.UnitAverage(), 'f', precision, 64),
+			strconv.FormatFloat(s.Median(), 'f', precision, 64),
+			strconv.FormatFloat(s.StdDev(), 'f', precision, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeSeriesCSV записывает ряд курсов валюты по датам в CSV со столбцами Date и Value
+func writeSeriesCSV(w io.Writer, series []exchangerates.RatePoint, precision int) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"Date", "Value"}); err != nil {
+		return err
+	}
+	for _, p := range series {
+		if err := writer.Write([]string{p.Date.Format(flagDateLayout), strconv.FormatFloat(p.Value, 'f', precision, 64)}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// charCodeRe проверяет символьный код валюты перед использованием в имени файла
+var charCodeRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// writeSplitCSV сохраняет ряд курсов каждой валюты в отдельный файл <dir>/<CharCode>.csv,
+// создавая каталог dir при необходимости. Коды, не состоящие из трёх заглавных латинских букв,
+// отклоняются до записи файлов, чтобы данные источника не могли указать путь вне каталога.
+func writeSplitCSV(dir string, stats map[string]exchangerates.CurrencyStats, precision int) error {
+	for code, s := range stats {
+		if !charCodeRe.MatchString(s.CharCode) {
+			return fmt.Errorf("Некорректный символьный код валюты %q для имени файла", code)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("Ошибка при создании каталога %s: %w", dir, err)
+	}
+
+	for _, s := range exchangerates.SortedStats(stats) {
+		path := filepath.Join(dir, s.CharCode+".csv")
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("Ошибка при создании файла %s: %w", path, err)
+		}
+		if err := writeSeriesCSV(file, s.Series, precision); err != nil {
+			file.Close()
+			return fmt.Errorf("Ошибка при записи файла %s: %w", path, err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("Ошибка при записи файла %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// writeMatrixCSV записывает курсы валют по датам в широкую таблицу CSV: строки — даты,
+// столбцы — символьные коды валют. Ячейки дат без курса валюты остаются пустыми.
+func writeMatrixCSV(w io.Writer, stats map[string]exchangerates.CurrencyStats, precision int) error {
+	list := exchangerates.SortedStats(stats)
+	header := []string{"Date"}
+	values := make(map[time.Time][]string)
+	for i, s := range list {
+		header = append(header, s.CharCode)
+		for _, p := range s.Series {
+			if values[p.Date] == nil {
+				values[p.Date] = make([]string, len(list))
+			}
+			values[p.Date][i] = strconv.FormatFloat(p.Value, 'f', precision, 64)
+		}
+	}
+
+	dates := make([]time.Time, 0, len(values))
+	for d := range values {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, d := range dates {
+		if err := writer.Write(append([]string{d.Format(flagDateLayout)}, values[d]...)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeMatrixFile сохраняет курсы валют по датам в CSV-файл по указанному пути (см. writeMatrixCSV)
+func writeMatrixFile(path string, stats map[string]exchangerates.CurrencyStats, precision int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Ошибка при создании файла %s: %w", path, err)
+	}
+
+	if err := writeMatrixCSV(file, stats, precision); err != nil {
+		file.Close()
+		return fmt.Errorf("Ошибка при записи файла %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// changesJSON описывает изменения курса валюты в JSON-выводе
+type changesJSON struct {
+	CharCode string                      `json:"char_code"` // Символьный код валюты
+	Changes  []exchangerates.ChangePoint `json:"changes"`   // Даты изменения курса
+}
+
+// writeChanges выводит по каждой валюте только даты изменения курса в формате text, table или json
+func writeChanges(w io.Writer, p *message.Printer, stats map[string]exchangerates.CurrencyStats, format string, precision int) error {
+	if format == "json" {
+		list := make([]changesJSON, 0, len(stats))
+		for _, s := range exchangerates.SortedStats(stats) {
+			item := changesJSON{CharCode: s.CharCode, Changes: []exchangerates.ChangePoint{}}
+			for _, c := range s.Changes() {
+				item.Changes = append(item.Changes, exchangerates.ChangePoint{
+					Date:     c.Date,
+					OldValue: roundTo(c.OldValue, precision),
+					NewValue: roundTo(c.NewValue, precision),
+				})
+			}
+			list = append(list, item)
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	}
+	if format != "text" && format != "table" {
+		return fmt.Errorf("Формат вывода %s не поддерживает вывод изменений курса", format)
+	}
+
+	for _, s := range exchangerates.SortedStats(stats) {
+		for _, c := range s.Changes() {
+			if _, err := fprintf(w, p, "%s %s: %.*f -> %.*f\n", s.CharCode, c.Date.Format(flagDateLayout),
+				precision, c.OldValue, precision, c.NewValue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// intervalJSON описывает статистику за интервал группировки в JSON-выводе
+type intervalJSON struct {
+	Start string      `json:"start"` // Первый день интервала (ГГГГ-ММ-ДД)
+	End   string      `json:"end"`   // Последний день интервала (ГГГГ-ММ-ДД)
+	Stats []statsJSON `json:"stats"` // Статистика по валютам за интервал
+}
+
+// writeIntervals выводит статистику по интервалам группировки в формате text, table или json.
+// В текстовых форматах статистика каждого интервала предваряется строкой с его границами.
+func writeIntervals(w io.Writer, p *message.Printer, groups []exchangerates.IntervalStats, format string, precision, window int) error {
+	if format == "json" {
+		list := make([]intervalJSON, 0, len(groups))
+		for _, g := range groups {
+			item := intervalJSON{Start: g.Start.Format(flagDateLayout), End: g.End.Format(flagDateLayout)}
+			for _, s := range exchangerates.SortedStats(g.Stats) {
+				item.Stats = append(item.Stats, newStatsJSON(s, precision, window))
+			}
+			list = append(list, item)
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	}
+	if format != "text" && format != "table" {
+		return fmt.Errorf("Формат вывода %s не поддерживает группировку по интервалам", format)
+	}
+
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if _, err := fmt.Fprintf(w, "%s — %s\n", g.Start.Format(flagDateLayout), g.End.Format(flagDateLayout)); err != nil {
+			return err
+		}
+		if err := writeStats(w, p, g.Stats, format, precision, window); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// basketJSON описывает индекс корзины валют для вывода в формате JSON
+type basketJSON struct {
+	Average float64                   `json:"average"` // Среднее значение индекса за период
+	Series  []exchangerates.RatePoint `json:"series"`  // Значения индекса по датам
+}
+
+// writeBasket выводит значения индекса корзины валют по датам и его среднее значение
+// в формате text, table или json
+func writeBasket(w io.Writer, p *message.Printer, points []exchangerates.RatePoint, format string, precision int) error {
+	var average float64
+	if len(points) > 0 {
+		for _, point := range points {
+			average += point.Value
+		}
+		average /= float64(len(points))
+	}
+
+	if format == "json" {
+		result := basketJSON{Average: roundTo(average, precision), Series: make([]exchangerates.RatePoint, len(points))}
+		for i, point := range points {
+			result.Series[i] = exchangerates.RatePoint{Date: point.Date, Value: roundTo(point.Value, precision)}
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	if format != "text" && format != "table" {
+		return fmt.Errorf("Формат вывода %s не поддерживает индекс корзины валют", format)
+	}
+
+	for _, point := range points {
+		if _, err := fprintf(w, p, "%s: %.*f\n", point.Date.Format(flagDateLayout), precision, point.Value); err != nil {
+			return err
+		}
+	}
+	_, err := fprintf(w, p, "Basket average: %.*f (%d days)\n", precision, average, len(points))
+	return err
+}
+
+// regionJSON описывает статистику по валютам региона в JSON-выводе
+type regionJSON struct {
+	Region        string      `json:"region"`         // Название региона
+	ChangePercent float64     `json:"change_percent"` // Среднее изменение курса валют региона в процентах
+	Stats         []statsJSON `json:"stats"`          // Статистика по валютам региона
+}
+
+// writeRegions выводит статистику по валютам, сгруппированную по регионам, в формате text, table или json.
+// В текстовых форматах статистика каждого региона предваряется строкой с его средним изменением курса.
+func writeRegions(w io.Writer, p *message.Printer, groups []exchangerates.RegionStats, format string, precision, window int) error {
+	if format == "json" {
+		list := make([]regionJSON, 0, len(groups))
+		for _, g := range groups {
+			item := regionJSON{Region: g.Region, ChangePercent: roundTo(g.ChangePercent, precision)}
+			for _, s := range exchangerates.SortedStats(g.Stats) {
+				item.Stats = append(item.Stats, newStatsJSON(s, precision, window))
+			}
+			list = append(list, item)
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(list)
+	}
+	if format != "text" && format != "table" {
+		return fmt.Errorf("Формат вывода %s не поддерживает группировку по регионам", format)
+	}
+
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if _, err := fprintf(w, p, "%s (average change %+.2f%%)\n", g.Region, g.ChangePercent); err != nil {
+			return err
+		}
+		if err := writeStats(w, p, g.Stats, format, precision, window); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStatsFile сохраняет статистику по валютам в файл по указанному пути в формате format (см. writeStats).
+// Числа в файле записываются без учёта локали вывода.
+func writeStatsFile(path string, stats map[string]exchangerates.CurrencyStats, format string, precision, window int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Ошибка при создании файла %s: %w", path, err)
+	}
+
+	if err := writeStats(file, nil, stats, format, precision, window); err != nil {
+		file.Close()
+		return fmt.Errorf("Ошибка при записи файла %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// writeStats выводит статистику по валютам в указанном формате (text, table, json или csv).
+// Скользящее среднее по окну window выводится в форматах text и json. В форматах text и table
+// числа форматируются с учётом локали форматировщика p (nil — без разделителей разрядов).
+func writeStats(w io.Writer, p *message.Printer, stats map[string]exchangerates.CurrencyStats, format string, precision, window int) error {
+	switch format {
+	case "text":
+		return writeText(w, p, stats, precision, window)
+	case "table":
+		return writeTable(w, p, stats, precision)
+	case "json":
+		return writeJSON(w, stats, precision, window)
+	case "csv":
+		return WriteCSV(w, stats, precision)
+	default:
+		return fmt.Errorf("Неизвестный формат вывода: %s", format)
+	}
+}
@@ -0,0 +1,775 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Alfarabi09/Exchange_Rates/exchangerates"
+)
+
+// testValute описывает курс валюты за один день в тестовых данных
+type testValute struct {
+	code, numCode, name string
+	nominal             int
+	values              []string // Значения курса по дням начиная с 2024-03-01
+}
+
+// testStore учитывает курсы валют за последовательные дни начиная с 2024-03-01
+func testStore(valutes ...testValute) *exchangerates.StatsStore {
+	store := exchangerates.NewStatsStore()
+	for day := 0; ; day++ {
+		date := time.Date(2024, 3, 1+day, 0, 0, 0, 0, time.UTC)
+		valCurs := exchangerates.ValCurs{Date: date.Format("02.01.2006"), Time: date}
+		for _, v := range valutes {
+			if day < len(v.values) {
+				valCurs.Valutes = append(valCurs.Valutes, exchangerates.Valute{
+					ID: "R" + v.numCode, NumCode: v.numCode, CharCode: v.code,
+					Nominal: v.nominal, Name: v.name, Value: v.values[day],
+				})
+			}
+		}
+		if len(valCurs.Valutes) == 0 {
+			return store
+		}
+		store.Update(valCurs)
+	}
+}
+
+// testStats рассчитывает статистику по курсам валют за последовательные дни начиная с 2024-03-01
+func testStats(t *testing.T, valutes ...testValute) map[string]exchangerates.CurrencyStats {
+	t.Helper()
+	return testStore(valutes...).Snapshot()
+}
+
+func TestWriteJSON(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "Доллар США", 1, []string{"90,1", "91,3", "92,12345"}},
+		testValute{"JPY", "392", "Японских иен", 100, []string{"60,5", "61,5"}},
+	)
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, stats, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	var got []struct {
+		CharCode    string  `json:"char_code"`
+		Name        string  `json:"name"`
+		Nominal     int     `json:"nominal"`
+		Count       int     `json:"count"`
+		Max         float64 `json:"max_value"`
+		MaxDate     string  `json:"max_date"`
+		Min         float64 `json:"min_value"`
+		Average     float64 `json:"average"`
+		UnitAverage float64 `json:"unit_average"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("некорректный JSON: %v\n%s", err, buf.String())
+	}
+
+	if len(got) != 2 || got[0].CharCode != "JPY" || got[1].CharCode != "USD" {
+		t.Fatalf("currencies = %+v, want JPY и USD", got)
+	}
+	jpy, usd := got[0], got[1]
+	if jpy.Nominal != 100 || jpy.Average != 61 || jpy.UnitAverage != 0.61 {
+		t.Errorf("JPY = %+v", jpy)
+	}
+	// Значения округляются до 4 знаков после запятой
+	if usd.Count != 3 || usd.Max != 92.1235 || usd.MaxDate != "03.03.2024" || usd.Min != 90.1 || usd.Average != 91.1745 {
+		t.Errorf("USD = %+v", usd)
+	}
+	if usd.Name != "Доллар США" {
+		t.Errorf("USD name = %q", usd.Name)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "Доллар США", 1, []string{"90,1", "91,3"}},
+		testValute{"XDR", "960", "СДР (специальные права заимствования, МВФ)", 1, []string{"120,5", "121,5"}},
+	)
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, stats, 4); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("некорректный CSV: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("rows = %d, want 3 (заголовок и две валюты)", len(records))
+	}
+	if records[0][0] != "CharCode" || records[0][8] != "Average" {
+		t.Errorf("header = %v", records[0])
+	}
+	usd, xdr := records[1], records[2]
+	if usd[0] != "USD" || usd[4] != "91.3000" || usd[5] != "02.03.2024" || usd[8] != "90.7000" {
+		t.Errorf("USD = %v", usd)
+	}
+	// Запятые в названии валюты экранируются кавычками и не разбивают строку
+	if xdr[2] != "СДР (специальные права заимствования, МВФ)" || xdr[6] != "120.5000" {
+		t.Errorf("XDR = %v", xdr)
+	}
+}
+
+func TestWriteSplitCSV(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "Доллар США", 1, []string{"90,1", "91,3", "92,5"}},
+		testValute{"JPY", "392", "Японских иен", 100, []string{"60,5", "61,5"}},
+	)
+	dir := filepath.Join(t.TempDir(), "split") // Каталог создаётся при записи
+
+	if err := writeSplitCSV(dir, stats, 2); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("файлов = %d, want 2", len(entries))
+	}
+
+	for code, want := range map[string][][]string{
+		"USD": {{"Date", "Value"}, {"2024-03-01", "90.10"}, {"2024-03-02", "91.30"}, {"2024-03-03", "92.50"}},
+		"JPY": {{"Date", "Value"}, {"2024-03-01", "60.50"}, {"2024-03-02", "61.50"}},
+	} {
+		file, err := os.Open(filepath.Join(dir, code+".csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(file).ReadAll()
+		file.Close()
+		if err != nil {
+			t.Fatalf("%s: некорректный CSV: %v", code, err)
+		}
+		if len(records) != len(want) {
+			t.Errorf("%s: rows = %d, want %d", code, len(records), len(want))
+			continue
+		}
+		for i := range want {
+			if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+				t.Errorf("%s: row %d = %v, want %v", code, i, records[i], want[i])
+			}
+		}
+	}
+}
+
+func TestWriteSplitCSVRejectsUnsafeCharCode(t *testing.T) {
+	for _, code := range []string{"../../evil", "US", "usd", "A/B"} {
+		t.Run(code, func(t *testing.T) {
+			stats := testStats(t, testValute{"USD", "840", "Доллар США", 1, []string{"90,1"}})
+			s := stats["USD"]
+			s.CharCode = code
+			stats = map[string]exchangerates.CurrencyStats{code: s}
+			dir := filepath.Join(t.TempDir(), "split")
+
+			if err := writeSplitCSV(dir, stats, 2); err == nil {
+				t.Fatal("ожидалась ошибка для некорректного кода")
+			}
+			// Каталог не создаётся, и файлы не записываются
+			if _, err := os.Stat(dir); !os.IsNotExist(err) {
+				t.Errorf("каталог %s создан: %v", dir, err)
+			}
+		})
+	}
+}
+
+func TestWriteChanges(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "Доллар США", 1, []string{"90", "90", "90", "91,5", "91,5"}},
+		testValute{"EUR", "978", "Евро", 1, []string{"98", "98"}},
+	)
+
+	var buf bytes.Buffer
+	if err := writeChanges(&buf, nil, stats, "text", 2); err != nil {
+		t.Fatal(err)
+	}
+	// Неизменный курс EUR не выводится
+	if want := "USD 2024-03-04: 90.00 -> 91.50\n"; buf.String() != want {
+		t.Errorf("writeChanges = %q, want %q", buf.String(), want)
+	}
+
+	if err := writeChanges(&buf, nil, stats, "csv", 2); err == nil {
+		t.Error("writeChanges(csv): ожидалась ошибка для неподдерживаемого формата")
+	}
+}
+
+func TestWriteRegions(t *testing.T) {
+	groups := exchangerates.GroupByRegion(testStats(t,
+		testValute{"USD", "840", "Доллар США", 1, []string{"90", "99"}},
+		testValute{"EUR", "978", "Евро", 1, []string{"100", "90"}},
+		testValute{"XYZ", "999", "Неизвестная валюта", 1, []string{"10", "11"}},
+	))
+
+	var buf bytes.Buffer
+	if err := writeRegions(&buf, nil, groups, "json", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	var got []struct {
+		Region        string  `json:"region"`
+		ChangePercent float64 `json:"change_percent"`
+		Stats         []struct {
+			CharCode string `json:"char_code"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("некорректный JSON: %v\n%s", err, buf.String())
+	}
+
+	want := []struct {
+		region, code string
+		change       float64
+	}{
+		{"Americas", "USD", 10},
+		{"Europe", "EUR", -10},
+		{"Other", "XYZ", 10},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("regions = %+v, want %d регионов", got, len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Region != w.region || g.ChangePercent != w.change || len(g.Stats) != 1 || g.Stats[0].CharCode != w.code {
+			t.Errorf("regions[%d] = %+v, want %s с %s и %+.0f%%", i, g, w.region, w.code, w.change)
+		}
+	}
+
+	buf.Reset()
+	if err := writeRegions(&buf, nil, groups, "text", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "Americas (average change +10.00%)\n") || !strings.Contains(buf.String(), "\nOther (average change +10.00%)\n") {
+		t.Errorf("writeRegions(text):\n%s", buf.String())
+	}
+	if err := writeRegions(&buf, nil, groups, "csv", 2, 0); err == nil {
+		t.Error("writeRegions(csv): ожидалась ошибка для неподдерживаемого формата")
+	}
+}
+
+func TestOutputPerUnit(t *testing.T) {
+	raw := testStats(t, testValute{"JPY", "392", "Японских иен", 100, []string{"60,5", "61,5", "62"}})
+	stats := toUnit(raw)
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, stats, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	var got []struct {
+		Nominal int     `json:"nominal"`
+		PerUnit bool    `json:"per_unit"`
+		Max     float64 `json:"max_value"`
+		Min     float64 `json:"min_value"`
+		Average float64 `json:"average"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	// Все значения курса за 100 иен делятся на номинал
+	if len(got) != 1 || !got[0].PerUnit || got[0].Nominal != 1 || got[0].Max != 0.62 || got[0].Min != 0.605 || got[0].Average != 0.6133 {
+		t.Errorf("JSON = %+v, want курсы за одну иену", got)
+	}
+	if last := stats["JPY"].Series[2].Value; last != 0.62 {
+		t.Errorf("последний курс = %v, want 0.62", last)
+	}
+
+	buf.Reset()
+	if err := writeText(&buf, nil, stats, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Nominal: 1 (per unit)", "Max: 0.6200 (03.03.2024)", "Min: 0.6050 (01.03.2024)", "Average: 0.6133", "Median: 0.6150", "Last: 0.6200"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("writeText не содержит %q:\n%s", want, buf.String())
+		}
+	}
+
+	// Исходная статистика не изменяется и выводится за номинал
+	if jpy := raw["JPY"]; jpy.PerUnit || jpy.Nominal != 100 || jpy.MaxValue != 62 {
+		t.Errorf("raw JPY = %+v, want курсы за 100 иен", jpy)
+	}
+}
+
+func TestWriteMatrixCSV(t *testing.T) {
+	// Курс EUR за 2024-03-02 отсутствует, курс USD за 2024-03-04 ещё не опубликован
+	stats := testStats(t,
+		testValute{"USD", "840", "Доллар США", 1, []string{"90,1", "91,3", "92"}},
+		testValute{"EUR", "978", "Евро", 1, []string{"98,5", "", "99", "99,25"}},
+	)
+
+	var buf bytes.Buffer
+	if err := writeMatrixCSV(&buf, stats, 2); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("некорректный CSV: %v", err)
+	}
+
+	want := [][]string{
+		{"Date", "EUR", "USD"},
+		{"2024-03-01", "98.50", "90.10"},
+		{"2024-03-02", "", "91.30"},
+		{"2024-03-03", "99.00", "92.00"},
+		{"2024-03-04", "99.25", ""},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("rows = %d, want %d:\n%s", len(records), len(want), buf.String())
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("row %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"", "1234.56"},
+		{"en", "1,234.56"},
+		{"ru", "1\u00a0234,56"}, // Разряды в русской локали разделяются неразрывным пробелом
+	}
+	for _, tt := range tests {
+		p, err := newAmountPrinter(tt.locale)
+		if err != nil {
+			t.Fatalf("newAmountPrinter(%q): %v", tt.locale, err)
+		}
+		if got := formatAmount(p, 1234.5631, 2); got != tt.want {
+			t.Errorf("formatAmount(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+
+	if _, err := newAmountPrinter("de"); err == nil {
+		t.Error("newAmountPrinter(de): ожидалась ошибка")
+	}
+}
+
+func TestWriteStatsLocale(t *testing.T) {
+	stats := testStats(t, testValute{"USD", "840", "Доллар США", 1, []string{"1234,5", "1234,5"}})
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"", "1234.50"},
+		{"en", "1,234.50"},
+		{"ru", "1\u00a0234,50"},
+	}
+	for _, tt := range tests {
+		p, err := newAmountPrinter(tt.locale)
+		if err != nil {
+			t.Fatalf("newAmountPrinter(%q): %v", tt.locale, err)
+		}
+		for _, format := range []string{"text", "table"} {
+			var buf bytes.Buffer
+			if err := writeStats(&buf, p, stats, format, 2, 0); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("%s %q: нет значения %q:\n%s", format, tt.locale, tt.want, buf.String())
+			}
+		}
+
+		// JSON остаётся машиночитаемым независимо от локали
+		var buf bytes.Buffer
+		if err := writeStats(&buf, p, stats, "json", 2, 0); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), `"average": 1234.5`) {
+			t.Errorf("json %q:\n%s", tt.locale, buf.String())
+		}
+	}
+}
+
+func TestWriteHeadline(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "Доллар США", 1, []string{"90", "95", "99"}},         // +10%
+		testValute{"EUR", "978", "Евро", 1, []string{"100", "97", "95"}},              // -5%
+		testValute{"CNY", "156", "Китайский юань", 1, []string{"12", "12,3", "12,6"}}, // +5%
+		testValute{"JPY", "392", "Японских иен", 100, []string{"60", "59,4", "58,8"}}, // -2%
+		testValute{"TRY", "949", "Турецких лир", 10, []string{"30"}},                  // Один день не учитывается
+	)
+	if strongest, weakest, ok := strongestWeakest(stats); !ok || strongest.CharCode != "USD" || weakest.CharCode != "EUR" {
+		t.Errorf("strongestWeakest = %s, %s, %v, want USD, EUR, true", strongest.CharCode, weakest.CharCode, ok)
+	}
+
+	var buf bytes.Buffer
+	if err := writeHeadline(&buf, nil, stats, exchangerates.BaseRUB); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Headline (against RUB): strongest USD (+10.00%), weakest EUR (-5.00%)\n"; buf.String() != want {
+		t.Errorf("writeHeadline = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := writeHeadline(&buf, nil, testStats(t, testValute{"USD", "840", "Доллар США", 1, []string{"90"}}), exchangerates.BaseRUB); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Headline: not enough data\n"; buf.String() != want {
+		t.Errorf("writeHeadline = %q, want %q", buf.String(), want)
+	}
+}
+
+// volatileStats возвращает статистику с заданным размахом колебаний курса при среднем 100
+func volatileStats(code string, spread float64) exchangerates.CurrencyStats {
+	return exchangerates.CurrencyStats{CharCode: code, MaxValue: 100 + spread/2, MinValue: 100 - spread/2, Average: 100, Count: 1, Nominal: 1}
+}
+
+func TestTopVolatile(t *testing.T) {
+	stats := map[string]exchangerates.CurrencyStats{}
+	for code, spread := range map[string]float64{"USD": 2, "EUR": 8, "TRY": 20, "CNY": 8, "JPY": 1} {
+		stats[code] = volatileStats(code, spread)
+	}
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{1, "TRY"},
+		{3, "TRY,CNY,EUR"}, // CNY и EUR с равной волатильностью упорядочиваются по коду
+		{4, "TRY,CNY,EUR,USD"},
+		{0, "TRY,CNY,EUR,USD,JPY"},
+		{10, "TRY,CNY,EUR,USD,JPY"},
+	}
+	for _, tt := range tests {
+		top := topVolatile(stats, tt.n)
+		list := exchangerates.SortedStats(top)
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Volatility() > list[j].Volatility() })
+		var got []string
+		for _, s := range list {
+			got = append(got, s.CharCode)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("topVolatile(%d) = %v, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTextSorted(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "Доллар США", 1, []string{"90"}},
+		testValute{"AUD", "036", "Австралийский доллар", 1, []string{"59"}},
+		testValute{"EUR", "978", "Евро", 1, []string{"98"}},
+		testValute{"CNY", "156", "Юань", 1, []string{"12"}},
+	)
+
+	var first string
+	for run := 0; run < 10; run++ {
+		var buf bytes.Buffer
+		if err := writeText(&buf, nil, stats, 4, 0); err != nil {
+			t.Fatal(err)
+		}
+		if run == 0 {
+			first = buf.String()
+			continue
+		}
+		if buf.String() != first {
+			t.Fatalf("вывод запуска %d отличается от первого:\n%s\n%s", run+1, buf.String(), first)
+		}
+	}
+
+	var codes []string
+	for _, line := range strings.Split(strings.TrimSpace(first), "\n") {
+		_, rest, _ := strings.Cut(line, "(")
+		codes = append(codes, rest[:3])
+	}
+	if got := strings.Join(codes, ","); got != "AUD,CNY,EUR,USD" {
+		t.Errorf("порядок валют = %s, want AUD,CNY,EUR,USD", got)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "US Dollar", 1, []string{"90", "92"}},
+		testValute{"JPY", "392", "Japanese Yen", 100, []string{"60,5"}},
+	)
+
+	var buf bytes.Buffer
+	if err := writeTable(&buf, nil, stats, 2); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %d, want 3:\n%s", len(lines), buf.String())
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "Code Name Nominal Max Min Avg Avg/Unit Days" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "JPY") || !strings.HasPrefix(lines[2], "USD") {
+		t.Errorf("rows = %q, want JPY и USD", lines[1:])
+	}
+
+	// Столбцы выровнены: каждый столбец начинается в одной позиции во всех строках
+	for _, column := range []string{"Nominal", "Max", "Avg/Unit"} {
+		pos := strings.Index(lines[0], column)
+		for _, line := range lines[1:] {
+			if pos >= len(line) || line[pos-1] != ' ' || line[pos] == ' ' {
+				t.Errorf("столбец %s не выровнен в строке %q", column, line)
+			}
+		}
+	}
+	if !strings.Contains(lines[2], "92.00") || !strings.Contains(lines[2], "91.00") {
+		t.Errorf("USD = %q", lines[2])
+	}
+}
+
+func TestOutputPrecision(t *testing.T) {
+	stats := testStats(t, testValute{"USD", "840", "US Dollar", 1, []string{"90,123456", "91,654321"}})
+
+	for _, tt := range []struct {
+		precision int
+		max, avg  string
+	}{
+		{2, "91.65", "90.89"},
+		{4, "91.6543", "90.8889"},
+		{6, "91.654321", "90.888889"},
+	} {
+		var text, table, csvOut, jsonOut bytes.Buffer
+		if err := writeText(&text, nil, stats, tt.precision, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeTable(&table, nil, stats, tt.precision); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteCSV(&csvOut, stats, tt.precision); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeJSON(&jsonOut, stats, tt.precision, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, out := range map[string]string{"text": text.String(), "table": table.String(), "csv": csvOut.String(), "json": jsonOut.String()} {
+			if !strings.Contains(out, tt.max) || !strings.Contains(out, tt.avg) {
+				t.Errorf("%s (precision %d): нет значений %s и %s:\n%s", name, tt.precision, tt.max, tt.avg, out)
+			}
+		}
+		if !strings.Contains(text.String(), "Max: "+tt.max+" ") {
+			t.Errorf("text (precision %d) = %q", tt.precision, text.String())
+		}
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "US Dollar", 1, []string{"90", "91", "92", "93"}},
+		testValute{"EUR", "978", "Euro", 1, []string{"98", "99"}},
+	)
+
+	if got := coverage(stats["USD"]); got != "4/4" {
+		t.Errorf("coverage(USD) = %q, want 4/4", got)
+	}
+	if got := coverage(stats["EUR"]); got != "2/4*" {
+		t.Errorf("coverage(EUR) = %q, want 2/4*", got)
+	}
+	if eur := stats["EUR"]; eur.Coverage() != 0.5 || eur.Complete() {
+		t.Errorf("EUR Coverage() = %v, Complete() = %v, want 0.5 и false", eur.Coverage(), eur.Complete())
+	}
+
+	var buf bytes.Buffer
+	if err := writeText(&buf, nil, stats, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Days: 2/4*") {
+		t.Errorf("output = %q, want Days: 2/4*", buf.String())
+	}
+}
+
+func TestMinCoverage(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "US Dollar", 1, []string{"90", "91", "92", "93"}},
+		testValute{"EUR", "978", "Euro", 1, []string{"98", "99", "100"}},
+		testValute{"CNY", "156", "China Yuan", 1, []string{"12", "12"}},
+		testValute{"TRY", "949", "Turkish Lira", 10, []string{"28"}},
+	)
+
+	tests := []struct {
+		threshold float64
+		want      string
+	}{
+		{0, "CNY,EUR,TRY,USD"},
+		{0.5, "CNY,EUR,USD"},
+		{0.75, "EUR,USD"},
+		{1, "USD"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, s := range exchangerates.SortedStats(minCoverage(stats, tt.threshold)) {
+			got = append(got, s.CharCode)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("minCoverage(%v) = %v, want %s", tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestWriteStatsFile(t *testing.T) {
+	stats := testStats(t, testValute{"USD", "840", "US Dollar", 1, []string{"90", "92"}})
+	path := filepath.Join(t.TempDir(), "rates.out")
+	// Существующий файл перезаписывается целиком
+	if err := os.WriteFile(path, []byte(strings.Repeat("старые данные\n", 100)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"text", "table", "json", "csv"} {
+		if err := writeStatsFile(path, stats, format, 2, 0); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want bytes.Buffer
+		if err := writeStats(&want, nil, stats, format, 2, 0); err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want.String() {
+			t.Errorf("%s: содержимое файла = %q, want %q", format, data, want.String())
+		}
+	}
+
+	if err := writeStatsFile(filepath.Join(t.TempDir(), "missing", "rates.out"), stats, "text", 2, 0); err == nil {
+		t.Error("ожидалась ошибка для несуществующего каталога")
+	}
+}
+
+func TestWriteComparison(t *testing.T) {
+	first := testStats(t,
+		testValute{"USD", "840", "US Dollar", 1, []string{"90", "92"}},
+		testValute{"EUR", "978", "Euro", 1, []string{"100", "100"}},
+		testValute{"CNY", "156", "China Yuan", 1, []string{"12"}}, // Нет во втором периоде
+	)
+	second := testStats(t,
+		testValute{"USD", "840", "US Dollar", 1, []string{"100", "102"}},
+		testValute{"EUR", "978", "Euro", 1, []string{"95"}},
+	)
+
+	var buf bytes.Buffer
+	if err := writeComparison(&buf, nil, first, second, "table", 2); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %d, want 3:\n%s", len(lines), buf.String())
+	}
+	want := [][]string{
+		{"Code", "Name", "Avg1", "Avg2", "Delta", "Change%"},
+		{"EUR", "Euro", "100.00", "95.00", "-5.00", "-5.00"},
+		{"USD", "US", "Dollar", "91.00", "101.00", "+10.00", "+10.99"},
+	}
+	for i, line := range lines {
+		if got := strings.Fields(line); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("line %d = %q, want %v", i, line, want[i])
+		}
+	}
+}
+
+func TestWriteComparisonFormats(t *testing.T) {
+	first := testStats(t, testValute{"USD", "840", "US Dollar", 1, []string{"90", "92"}})
+	second := testStats(t, testValute{"USD", "840", "US Dollar", 1, []string{"100", "102"}})
+
+	var buf bytes.Buffer
+	if err := writeComparison(&buf, nil, first, second, "json", 2); err != nil {
+		t.Fatal(err)
+	}
+	var got []comparisonJSON
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("некорректный JSON: %v\n%s", err, buf.String())
+	}
+	want := comparisonJSON{CharCode: "USD", Name: "US Dollar", FirstAverage: 91, SecondAverage: 101, Delta: 10, ChangePercent: 10.99}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("json = %+v, want %+v", got, want)
+	}
+
+	buf.Reset()
+	if err := writeComparison(&buf, nil, first, second, "csv", 2); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("некорректный CSV: %v", err)
+	}
+	wantCSV := "CharCode,Name,FirstAverage,SecondAverage,Delta,ChangePercent|USD,US Dollar,91.00,101.00,10.00,10.99"
+	var rows []string
+	for _, r := range records {
+		rows = append(rows, strings.Join(r, ","))
+	}
+	if strings.Join(rows, "|") != wantCSV {
+		t.Errorf("csv = %v, want %s", rows, wantCSV)
+	}
+
+	if err := writeComparison(&buf, nil, first, second, "xml", 2); err == nil {
+		t.Error("writeComparison(xml): ожидалась ошибка для неизвестного формата")
+	}
+}
+
+func TestWriteJSONMovingAverage(t *testing.T) {
+	stats := testStats(t, testValute{"USD", "840", "US Dollar", 1, []string{"90", "91", "95", "96"}})
+
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, stats, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	var got []struct {
+		MovingAverage []exchangerates.RatePoint `json:"moving_average"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0].MovingAverage) != 3 {
+		t.Fatalf("moving_average = %+v, want 3 значения", got)
+	}
+	for i, want := range []float64{90.5, 93, 95.5} {
+		if v := got[0].MovingAverage[i].Value; v != want {
+			t.Errorf("moving_average[%d] = %v, want %v", i, v, want)
+		}
+	}
+
+	buf.Reset()
+	if err := writeText(&buf, nil, stats, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ", MA2: 95.50") {
+		t.Errorf("text = %q, want последнее значение MA2", buf.String())
+	}
+}
+
+func TestTableAndJSONFile(t *testing.T) {
+	stats := testStats(t,
+		testValute{"USD", "840", "US Dollar", 1, []string{"90", "92"}},
+		testValute{"EUR", "978", "Euro", 1, []string{"98"}},
+	)
+	jsonPath := filepath.Join(t.TempDir(), "rates.json")
+
+	// Таблица выводится в стандартный вывод, JSON сохраняется в файл (-format table -json-out)
+	var stdout bytes.Buffer
+	if err := writeStats(&stdout, nil, stats, "table", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeStatsFile(jsonPath, stats, "json", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(stdout.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "Code") {
+		t.Errorf("table = %q", stdout.String())
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []statsJSON
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatalf("некорректный JSON: %v", err)
+	}
+	if len(list) != 2 || list[0].CharCode != "EUR" || list[1].Average != 91 {
+		t.Errorf("json = %+v", list)
+	}
+}
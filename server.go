@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/Alfarabi09/Exchange_Rates/exchangerates"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// shutdownTimeout задаёт время на завершение активных запросов при остановке сервера
+const shutdownTimeout = 5 * time.Second
+
+// newServer возвращает обработчик HTTP API со статистикой по валютам:
+// /rates — все валюты, /rates/{code} — одна валюта, /healthz — проверка доступности,
+// /metrics — метрики Prometheus
+func newServer(store *exchangerates.StatsStore) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("GET /rates", func(w http.ResponseWriter, r *http.Request) {
+		stats := exchangerates.SortedStats(store.Snapshot())
+		list := make([]statsJSON, 0, len(stats))
+		for _, s := range stats {
+			list = append(list, newStatsJSON(s, defaultPrecision, 0))
+		}
+		writeJSONResponse(w, http.StatusOK, list)
+	})
+
+	mux.HandleFunc("GET /rates/{code}", func(w http.ResponseWriter, r *http.Request) {
+		code := strings.ToUpper(r.PathValue("code"))
+		s, ok := store.Snapshot()[code]
+		if !ok {
+			writeJSONResponse(w, http.StatusNotFound, map[string]string{"error": "валюта " + code + " не найдена"})
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, newStatsJSON(s, defaultPrecision, 0))
+	})
+
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	return mux
+}
+
+// writeJSONResponse отправляет значение v в формате JSON с указанным HTTP-статусом
+func writeJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Не удалось отправить ответ", "error", err)
+	}
+}
+
+// serve запускает HTTP API по адресу addr и останавливает его при отмене ctx
+func serve(ctx context.Context, addr string, store *exchangerates.StatsStore) error {
+	server := &http.Server{Addr: addr, Handler: newServer(store)}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("HTTP API запущен", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newProfiler возвращает обработчик профилирования net/http/pprof по путям /debug/pprof/
+func newProfiler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// startProfiler запускает в фоне сервер профилирования по адресу addr и возвращает
+// фактический адрес прослушивания. Сервер работает до завершения программы.
+func startProfiler(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	go func() {
+		if err := http.Serve(listener, newProfiler()); err != nil {
+			slog.Warn("Сервер профилирования остановлен", "error", err)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
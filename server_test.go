@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// getJSON выполняет GET-запрос к обработчику и разбирает JSON-ответ в v. Возвращает HTTP-статус.
+func getJSON(t *testing.T, handler http.Handler, path string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("%s: Content-Type = %q", path, ct)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("%s: некорректный JSON: %v\n%s", path, err, rec.Body.String())
+	}
+	return rec.Code
+}
+
+func TestServerRates(t *testing.T) {
+	handler := newServer(testStore(
+		testValute{"USD", "840", "Доллар США", 1, []string{"90", "92"}},
+		testValute{"EUR", "978", "Евро", 1, []string{"98"}},
+	))
+
+	var list []statsJSON
+	if code := getJSON(t, handler, "/rates", &list); code != http.StatusOK {
+		t.Errorf("/rates: status = %d", code)
+	}
+	if len(list) != 2 || list[0].CharCode != "EUR" || list[1].CharCode != "USD" {
+		t.Fatalf("/rates = %+v, want EUR и USD", list)
+	}
+
+	var usd statsJSON
+	if code := getJSON(t, handler, "/rates/usd", &usd); code != http.StatusOK {
+		t.Errorf("/rates/usd: status = %d", code)
+	}
+	if usd.CharCode != "USD" || usd.Average != 91 || usd.Count != 2 {
+		t.Errorf("/rates/usd = %+v", usd)
+	}
+}
+
+func TestServerUnknownCurrency(t *testing.T) {
+	handler := newServer(testStore(testValute{"USD", "840", "Доллар США", 1, []string{"90"}}))
+
+	var body map[string]string
+	if code := getJSON(t, handler, "/rates/XYZ", &body); code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", code)
+	}
+	if body["error"] == "" {
+		t.Errorf("body = %v, want описание ошибки", body)
+	}
+}
+
+func TestServerHealthz(t *testing.T) {
+	var body map[string]string
+	if code := getJSON(t, newServer(testStore()), "/healthz", &body); code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("/healthz: status = %d, body = %v", code, body)
+	}
+}
+
+func TestStartProfiler(t *testing.T) {
+	addr, err := startProfiler("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get("http://" + addr + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goroutine") {
+		t.Errorf("GET /debug/pprof/: status = %d, want 200 и список профилей", resp.StatusCode)
+	}
+
+	// Занятый адрес возвращает ошибку, не останавливая программу
+	if _, err := startProfiler(addr); err == nil {
+		t.Errorf("startProfiler(%s): ожидалась ошибка для занятого адреса", addr)
+	}
+}
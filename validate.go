@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Alfarabi09/Exchange_Rates/exchangerates"
+)
+
+// validationReport содержит результаты проверки полноты и корректности курсов за период
+type validationReport struct {
+	Requested int                    // Количество запрошенных дней
+	Valid     int                    // Количество дней с корректно разобранными курсами
+	Missing   []time.Time            // Дни, за которые курсы не публиковались или ответ пуст
+	Failed    []time.Time            // Дни с ошибками загрузки или разбора
+	Gaps      map[string][]time.Time // Дни без курса валюты среди дней с корректными курсами
+}
+
+// ok определяет, пройдена ли проверка: все дни загружены и ни у одной валюты нет пропусков
+func (r validationReport) ok() bool {
+	return len(r.Missing) == 0 && len(r.Failed) == 0 && len(r.Gaps) == 0
+}
+
+// validate загружает и разбирает курсы за период cfg, не рассчитывая статистику,
+// и проверяет, что за каждый день курсы опубликованы и разобраны, а у валют нет пропусков.
+// Учитываются только валюты cfg.Currencies, если список не пуст.
+func validate(ctx context.Context, cfg exchangerates.Config) (validationReport, error) {
+	dates := cfg.RequestDates()
+	report := validationReport{Requested: len(dates), Gaps: make(map[string][]time.Time)}
+
+	var days []time.Time
+	present := make(map[string]map[time.Time]bool)
+	for _, code := range cfg.Currencies {
+		present[code] = make(map[time.Time]bool)
+	}
+
+	for result := range exchangerates.FetchBatches(ctx, cfg.Source, dates, cfg.Concurrency, cfg.DayTimeout, cfg.BatchSize, cfg.BatchPause) {
+		switch {
+		case errors.Is(result.Err, context.Canceled):
+			continue
+		case errors.Is(result.Err, exchangerates.ErrStaleDate), errors.Is(result.Err, exchangerates.ErrNoRates):
+			report.Missing = append(report.Missing, result.Date)
+			continue
+		case result.Err != nil:
+			report.Failed = append(report.Failed, result.Date)
+			continue
+		case !result.ValCurs.MatchesRequest():
+			// ЦБ РФ вернул курсы за предыдущий рабочий день
+			report.Missing = append(report.Missing, result.Date)
+			continue
+		}
+
+		report.Valid++
+		days = append(days, result.Date)
+		for _, valute := range result.ValCurs.Valutes {
+			code := strings.ToUpper(valute.CharCode)
+			if len(cfg.Currencies) > 0 && !slices.Contains(cfg.Currencies, code) {
+				continue
+			}
+			if _, err := valute.FloatValue(); err != nil {
+				continue
+			}
+			if present[code] == nil {
+				present[code] = make(map[time.Time]bool)
+			}
+			present[code][result.Date] = true
+		}
+	}
+	if ctx.Err() != nil {
+		return report, fmt.Errorf("Проверка прервана: %w", ctx.Err())
+	}
+
+	for code, seen := range present {
+		for _, d := range days {
+			if !seen[d] {
+				report.Gaps[code] = append(report.Gaps[code], d)
+			}
+		}
+	}
+
+	sortDates(report.Missing)
+	sortDates(report.Failed)
+	for _, gaps := range report.Gaps {
+		sortDates(gaps)
+	}
+	return report, nil
+}
+
+// sortDates упорядочивает даты по возрастанию
+func sortDates(dates []time.Time) {
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+}
+
+// formatDates возвращает даты в формате ГГГГ-ММ-ДД через запятую
+func formatDates(dates []time.Time) string {
+	list := make([]string, len(dates))
+	for i, d := range dates {
+		list[i] = d.Format(flagDateLayout)
+	}
+	return strings.Join(list, ", ")
+}
+
+// writeValidation выводит результаты проверки курсов за период
+func writeValidation(w io.Writer, r validationReport) error {
+	fmt.Fprintf(w, "Days: %d/%d valid\n", r.Valid, r.Requested)
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(w, "Missing: %s\n", formatDates(r.Missing))
+	}
+	if len(r.Failed) > 0 {
+		fmt.Fprintf(w, "Failed: %s\n", formatDates(r.Failed))
+	}
+
+	codes := make([]string, 0, len(r.Gaps))
+	for code := range r.Gaps {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "Gaps %s: %s\n", code, formatDates(r.Gaps[code]))
+	}
+
+	status := "OK"
+	if !r.ok() {
+		status = "FAILED"
+	}
+	_, err := fmt.Fprintf(w, "Validation: %s\n", status)
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Alfarabi09/Exchange_Rates/exchangerates"
+)
+
+// brokenDayServer возвращает курс USD за рабочие дни 4–6 марта 2024 года,
+// ответ за 05.03.2024 обрезан и не разбирается
+func brokenDayServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		date := strings.ReplaceAll(r.URL.Query().Get("date_req"), "/", ".")
+		if date == "05.03.2024" {
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="windows-1251"?><ValCurs Date="%s"><Valute ID="R01235"><NumCode>840`, date)
+			return
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="windows-1251"?><ValCurs Date="%s" name="Foreign Currency Market">`+
+			`<Valute ID="R01235"><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>US Dollar</Name><Value>90</Value></Valute></ValCurs>`,
+			date)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestValidateBrokenDay(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	server := brokenDayServer(t)
+	source := &exchangerates.Fetcher{BaseURL: server.URL + "/?date_req=%s", Client: server.Client()}
+	cfg := exchangerates.NewConfig(source, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC))
+
+	report, err := validate(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ok() || report.Requested != 3 || report.Valid != 2 || formatDates(report.Failed) != "2024-03-05" {
+		t.Errorf("report = %+v, want 2/3 и ошибка за 2024-03-05", report)
+	}
+	if len(report.Missing) != 0 || len(report.Gaps) != 0 {
+		t.Errorf("Missing = %v, Gaps = %v, want пусто", report.Missing, report.Gaps)
+	}
+
+	var buf bytes.Buffer
+	if err := writeValidation(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Days: 2/3 valid\nFailed: 2024-03-05\nValidation: FAILED\n"; buf.String() != want {
+		t.Errorf("writeValidation = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestValidateGaps(t *testing.T) {
+	captureLog(t, slog.LevelError)
+	source := stubSource{values: map[string]string{"USD": "90", "EUR": "98"}}
+	cfg := exchangerates.NewConfig(source, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC))
+	cfg.Currencies = []string{"USD", "JPY"} // JPY отсутствует во всех днях
+
+	report, err := validate(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid != 2 || len(report.Gaps) != 1 || formatDates(report.Gaps["JPY"]) != "2024-03-04, 2024-03-05" {
+		t.Errorf("report = %+v, want пропуски только у JPY", report)
+	}
+}
+
+func TestValidateExitCode(t *testing.T) {
+	server := brokenDayServer(t)
+	tests := []struct {
+		name   string
+		end    string
+		code   int
+		output string
+	}{
+		{"корректные дни", "2024-03-04", 0, "Validation: OK"},
+		{"день с ошибкой", "2024-03-06", 1, "Failed: 2024-03-05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout := runMain(t, "-validate", "-no-cache", "-retries", "0", "-parse-retries", "0", "-log-level", "error",
+				"-base-url", server.URL+"/?date_req=%s", "-start", "2024-03-04", "-end", tt.end)
+			if code != tt.code || !strings.Contains(stdout, tt.output) {
+				t.Errorf("код выхода = %d, want %d; вывод:\n%s", code, tt.code, stdout)
+			}
+		})
+	}
+}